@@ -0,0 +1,144 @@
+package backlog
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/tedla-brandsema/tribble/internal/fio"
+	"github.com/tedla-brandsema/tribble/task"
+	"io"
+	"log/slog"
+)
+
+// Codec encodes and decodes a list of tasks in one serialization format.
+type Codec interface {
+	// Ext returns the file extension, including the dot, used for the format.
+	Ext() string
+	Encode(w io.Writer, tasks []*task.Task) error
+	Decode(r io.Reader) ([]*task.Task, error)
+}
+
+var (
+	Markdown Codec = markdownCodec{}
+	JSON     Codec = jsonCodec{}
+	Binary   Codec = binaryCodec{}
+)
+
+type markdownCodec struct{}
+
+func (markdownCodec) Ext() string {
+	return ".md"
+}
+
+func (markdownCodec) Encode(w io.Writer, tasks []*task.Task) error {
+	return Render(w, tasks)
+}
+
+func (markdownCodec) Decode(r io.Reader) ([]*task.Task, error) {
+	return Parse(r)
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Ext() string {
+	return ".json"
+}
+
+func (jsonCodec) Encode(w io.Writer, tasks []*task.Task) error {
+	if tasks == nil {
+		tasks = []*task.Task{}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	return enc.Encode(tasks)
+}
+
+func (jsonCodec) Decode(r io.Reader) ([]*task.Task, error) {
+	var tasks []*task.Task
+
+	err := json.NewDecoder(r).Decode(&tasks)
+	if err != nil {
+		return nil, err
+	}
+
+	return validate(tasks)
+}
+
+// binaryCodec stores every task as a gob encoded, checksummed frame. Files that
+// still use plain length prefixed records are read as well.
+type binaryCodec struct{}
+
+func (binaryCodec) Ext() string {
+	return ".bin"
+}
+
+func (binaryCodec) Encode(w io.Writer, tasks []*task.Task) error {
+	rw := fio.NewFrameWriter(w)
+	for _, t := range tasks {
+		var b []byte
+		buff := bytes.NewBuffer(b)
+
+		err := gob.NewEncoder(buff).Encode(t)
+		if err != nil {
+			return err
+		}
+
+		_, err = rw.WriteRecord(buff.Bytes())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (binaryCodec) Decode(r io.Reader) ([]*task.Task, error) {
+	var tasks []*task.Task
+
+	rr, err := fio.DetectRecordReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	for rec, err := range rr.Records() {
+		if errors.Is(err, fio.ErrTornRecord) {
+			// An interrupted append: everything before it is intact.
+			slog.Warn("ignoring partially written task",
+				slog.Int64("offset", rec.Offset),
+				slog.Any("error", err),
+			)
+			break
+		}
+		if errors.Is(err, fio.ErrChecksum) {
+			// Skipping the task would drop it from the file on the next save,
+			// leave it to the user to recover the file.
+			return nil, fmt.Errorf("%w, tribble recover can drop the damaged task", err)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var t task.Task
+		err = gob.NewDecoder(bytes.NewReader(rec.Data)).Decode(&t)
+		if err != nil {
+			return nil, fmt.Errorf("record at offset %d: %w", rec.Offset, err)
+		}
+		tasks = append(tasks, &t)
+	}
+
+	return validate(tasks)
+}
+
+// validate normalizes decoded tasks, as the setters do, and then validates
+// them.
+func validate(tasks []*task.Task) ([]*task.Task, error) {
+	for _, t := range tasks {
+		t.Normalize()
+		if err := t.Validate(); err != nil {
+			return nil, fmt.Errorf("task %s: %w", t.ID, err)
+		}
+	}
+	return tasks, nil
+}
@@ -0,0 +1,130 @@
+package backlog
+
+import (
+	"bytes"
+	"errors"
+	"github.com/tedla-brandsema/tribble/internal/fio"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+var codecs = []struct {
+	name  string
+	codec Codec
+}{
+	{name: "Markdown", codec: Markdown},
+	{name: "JSON", codec: JSON},
+	{name: "Binary", codec: Binary},
+}
+
+func TestCodecs(t *testing.T) {
+	for _, c := range codecs {
+		t.Run(c.name, func(t *testing.T) {
+			tasks := testTasks(t)
+
+			var buff bytes.Buffer
+			if err := c.codec.Encode(&buff, tasks); err != nil {
+				t.Fatalf("Failed to encode tasks: %v", err)
+			}
+
+			decoded, err := c.codec.Decode(&buff)
+			if err != nil {
+				t.Fatalf("Failed to decode tasks: %v", err)
+			}
+			if !reflect.DeepEqual(decoded, tasks) {
+				t.Fatalf("Expected tasks %+v, got %+v", tasks, decoded)
+			}
+		})
+
+		t.Run(c.name+" without tasks", func(t *testing.T) {
+			var buff bytes.Buffer
+			if err := c.codec.Encode(&buff, nil); err != nil {
+				t.Fatalf("Failed to encode tasks: %v", err)
+			}
+
+			decoded, err := c.codec.Decode(&buff)
+			if err != nil {
+				t.Fatalf("Failed to decode tasks: %v", err)
+			}
+			if len(decoded) != 0 {
+				t.Fatalf("Expected no tasks, got %d", len(decoded))
+			}
+		})
+	}
+}
+
+func TestStore(t *testing.T) {
+	for _, c := range codecs {
+		t.Run(c.name, func(t *testing.T) {
+			store := NewStore(fio.Disk, filepath.Join(t.TempDir(), "tasks"+c.codec.Ext()), c.codec)
+
+			loaded, err := store.Load()
+			if err != nil {
+				t.Fatalf("Failed to load missing store: %v", err)
+			}
+			if len(loaded) != 0 {
+				t.Fatalf("Expected no tasks, got %d", len(loaded))
+			}
+
+			tasks := testTasks(t)
+			if err := store.Save(tasks); err != nil {
+				t.Fatalf("Failed to save tasks: %v", err)
+			}
+
+			loaded, err = store.Load()
+			if err != nil {
+				t.Fatalf("Failed to load tasks: %v", err)
+			}
+			if !reflect.DeepEqual(loaded, tasks) {
+				t.Fatalf("Expected tasks %+v, got %+v", tasks, loaded)
+			}
+		})
+	}
+}
+
+func TestBinaryTornTask(t *testing.T) {
+	tasks := testTasks(t)
+
+	var buff bytes.Buffer
+	if err := Binary.Encode(&buff, tasks); err != nil {
+		t.Fatalf("Failed to encode tasks: %v", err)
+	}
+
+	// Simulate a crash halfway through appending the last task.
+	var last bytes.Buffer
+	if err := Binary.Encode(&last, tasks[1:]); err != nil {
+		t.Fatalf("Failed to encode task: %v", err)
+	}
+	torn := buff.Bytes()[:buff.Len()-last.Len()/2]
+
+	decoded, err := Binary.Decode(bytes.NewReader(torn))
+	if err != nil {
+		t.Fatalf("Failed to decode tasks: %v", err)
+	}
+	if len(decoded) != 1 || !decoded[0].Equal(tasks[0]) {
+		t.Fatalf("Expected only the intact task, got %+v", decoded)
+	}
+}
+
+func TestBinaryDamagedTask(t *testing.T) {
+	tasks := testTasks(t)
+
+	var buff bytes.Buffer
+	if err := Binary.Encode(&buff, tasks); err != nil {
+		t.Fatalf("Failed to encode tasks: %v", err)
+	}
+
+	// Damage the first task, the one after it is intact.
+	var first bytes.Buffer
+	if err := Binary.Encode(&first, tasks[:1]); err != nil {
+		t.Fatalf("Failed to encode task: %v", err)
+	}
+	damaged := buff.Bytes()
+	damaged[first.Len()-1] ^= 0xFF
+
+	_, err := Binary.Decode(bytes.NewReader(damaged))
+	if !errors.Is(err, fio.ErrChecksum) {
+		t.Fatalf("Expected error %v, got %v", fio.ErrChecksum, err)
+	}
+}
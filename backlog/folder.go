@@ -0,0 +1,438 @@
+package backlog
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/tedla-brandsema/tribble/internal/fio"
+	"github.com/tedla-brandsema/tribble/task"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// OrderFile is the file in a task folder that lists the ids of the tasks in
+// backlog order, one per line.
+const OrderFile = ".order"
+
+// shortID is the number of hex digits of the id in a task file name. Longer
+// ids are only used when two tasks would otherwise share a file.
+const shortID = 8
+
+// Folder keeps every task in a file of its own, serialized by its Codec, so
+// changes to different tasks never touch the same file. The file names come
+// from the titles, see NewFolder, and the order of the tasks is kept in
+// OrderFile.
+type Folder struct {
+	fs    fio.FS
+	path  string
+	codec Codec
+	name  func(title, id string) string
+}
+
+// NewFolder returns a Folder in path on fsys. name returns the path, without
+// extension, of the file of a task with the given title and id, which is
+// a prefix of the hex digits of the task id.
+func NewFolder(fsys fio.FS, path string, codec Codec, name func(title, id string) string) *Folder {
+	return &Folder{
+		fs:    fsys,
+		path:  path,
+		codec: codec,
+		name:  name,
+	}
+}
+
+func (f *Folder) Path() string {
+	return f.path
+}
+
+func (f *Folder) Codec() Codec {
+	return f.codec
+}
+
+// Entry is a task and the name of the file it is stored in.
+type Entry struct {
+	File string
+	Task *task.Task
+}
+
+// Load reads all tasks from the folder in backlog order. A missing folder is
+// treated as an empty list of tasks.
+func (f *Folder) Load() ([]*task.Task, error) {
+	entries, err := f.Entries()
+	if err != nil {
+		return nil, err
+	}
+	return tasksOf(entries), nil
+}
+
+// Entries returns the tasks in backlog order along with their files.
+func (f *Folder) Entries() ([]Entry, error) {
+	files, err := f.read()
+	if err != nil {
+		return nil, err
+	}
+	return ReadFolder(files, f.codec)
+}
+
+// Files returns the names of the task files of the folder's codec without
+// reading them.
+func (f *Folder) Files() ([]string, error) {
+	dir, err := f.fs.ReadDir(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, e := range dir {
+		if f.isTaskFile(e) {
+			files = append(files, e.Name())
+		}
+	}
+	return files, nil
+}
+
+func (f *Folder) isTaskFile(e fs.DirEntry) bool {
+	return !e.IsDir() && e.Name() != OrderFile && filepath.Ext(e.Name()) == f.codec.Ext()
+}
+
+// read returns the contents of the task files of the folder's codec and of
+// the order file.
+func (f *Folder) read() (map[string][]byte, error) {
+	dir, err := f.fs.ReadDir(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string][]byte)
+	for _, e := range dir {
+		if !f.isTaskFile(e) && e.Name() != OrderFile {
+			continue
+		}
+		content, err := f.fs.ReadFile(filepath.Join(f.path, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		files[e.Name()] = content
+	}
+	return files, nil
+}
+
+// ReadFolder decodes the files of a task folder, given by name, in backlog
+// order. Every file is decoded by the codec of its extension; files without
+// one are skipped. Tasks missing from the order file follow the others in the
+// order they were created.
+//
+// A task found in two files, which a rename interrupted between writing the
+// new file and removing the old one leaves behind, is read from the file it
+// was modified in last. The other file is left out, and removed by the next
+// Folder.Save.
+func ReadFolder(files map[string][]byte, codecs ...Codec) ([]Entry, error) {
+	var entries []Entry
+	seen := make(map[uuid.UUID]int)
+	for name, content := range files {
+		i := slices.IndexFunc(codecs, func(c Codec) bool { return c.Ext() == filepath.Ext(name) })
+		if i < 0 {
+			continue
+		}
+
+		tasks, err := codecs[i].Decode(bytes.NewReader(content))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		if len(tasks) != 1 {
+			return nil, fmt.Errorf("%s: expected one task, got %d", name, len(tasks))
+		}
+
+		e := Entry{File: name, Task: tasks[0]}
+		j, ok := seen[e.Task.ID]
+		if !ok {
+			seen[e.Task.ID] = len(entries)
+			entries = append(entries, e)
+			continue
+		}
+		kept, dropped := entries[j], e
+		if newer(e, kept) {
+			kept, dropped = e, kept
+		}
+		slog.Warn("task found in two files: skipping the older one",
+			slog.String("id", e.Task.ID.String()),
+			slog.String("file", kept.File),
+			slog.String("skipped", dropped.File),
+		)
+		entries[j] = kept
+	}
+
+	order := make(map[uuid.UUID]int)
+	for i, id := range parseOrder(files[OrderFile]) {
+		order[id] = i
+	}
+	slices.SortFunc(entries, func(a, b Entry) int {
+		ai, aok := order[a.Task.ID]
+		bi, bok := order[b.Task.ID]
+		switch {
+		case aok && bok:
+			return ai - bi
+		case aok:
+			return -1
+		case bok:
+			return 1
+		}
+		if c := a.Task.Created.Compare(b.Task.Created); c != 0 {
+			return c
+		}
+		return strings.Compare(a.File, b.File)
+	})
+
+	return entries, nil
+}
+
+// newer reports whether a holds a later version of a task than b. Files
+// modified at the same time are ordered by name, so the choice does not depend
+// on the order they are read in.
+func newer(a, b Entry) bool {
+	if c := a.Task.Modified.Compare(b.Task.Modified); c != 0 {
+		return c > 0
+	}
+	return a.File < b.File
+}
+
+// Save replaces the stored tasks with the given tasks. Tasks keep their file
+// as long as their title does not change; a task whose title changed is moved
+// to a file named after the new title.
+func (f *Folder) Save(tasks []*task.Task) error {
+	err := f.fs.MakeDir(f.path)
+	if err != nil {
+		return err
+	}
+
+	files, err := f.read()
+	if err != nil {
+		return err
+	}
+	current, err := ReadFolder(files, f.codec)
+	if err != nil {
+		return err
+	}
+	owners := make(map[string]uuid.UUID, len(current))
+	previous := make(map[uuid.UUID]string, len(current))
+	for _, e := range current {
+		owners[e.File] = e.Task.ID
+		previous[e.Task.ID] = e.File
+	}
+
+	used := make(map[string]bool, len(tasks))
+	for _, t := range tasks {
+		name := f.fileName(t, previous[t.ID], owners, used)
+		if name == "" {
+			return fmt.Errorf("task %s is listed twice", t.ID)
+		}
+		used[name] = true
+
+		var b []byte
+		buff := bytes.NewBuffer(b)
+		err = f.codec.Encode(buff, []*task.Task{t})
+		if err != nil {
+			return err
+		}
+		if content, ok := files[name]; ok && bytes.Equal(content, buff.Bytes()) {
+			continue
+		}
+		err = f.fs.OverwriteFile(filepath.Join(f.path, name), buff.Bytes())
+		if err != nil {
+			return err
+		}
+	}
+
+	// Every task file is looked at, not only the ones read, so the older file
+	// of a task found twice is removed as well.
+	for name := range files {
+		if used[name] || name == OrderFile {
+			continue
+		}
+		err = f.fs.Remove(filepath.Join(f.path, name))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	ids := make([]uuid.UUID, 0, len(tasks))
+	for _, t := range tasks {
+		ids = append(ids, t.ID)
+	}
+	order := formatOrder(ids)
+	if bytes.Equal(files[OrderFile], order) {
+		return nil
+	}
+	return f.fs.OverwriteFile(filepath.Join(f.path, OrderFile), order)
+}
+
+// fileName returns the file of t. Its current file is kept if it still
+// matches the title, otherwise the shortest id that makes the name unique is
+// used.
+func (f *Folder) fileName(t *task.Task, current string, owners map[string]uuid.UUID, used map[string]bool) string {
+	id := strings.ReplaceAll(t.ID.String(), "-", "")
+
+	var free string
+	for n := shortID; n <= len(id); n++ {
+		name := filepath.Base(f.name(t.Title, id[:n])) + f.codec.Ext()
+		if used[name] {
+			continue
+		}
+		if name == current {
+			return name
+		}
+		if owner, ok := owners[name]; free == "" && (!ok || owner == t.ID) {
+			free = name
+		}
+	}
+	return free
+}
+
+// Remove removes the task files of the folder's codec, leaving the order file
+// and the files of other codecs.
+func (f *Folder) Remove() error {
+	files, err := f.read()
+	if err != nil {
+		return err
+	}
+
+	for name := range files {
+		if name == OrderFile {
+			continue
+		}
+		err = f.fs.Remove(filepath.Join(f.path, name))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// RemoveTemp removes the temporary files that interrupted writes left in the
+// folder, see fio.IsTempFile, so they are not committed along with the tasks.
+func (f *Folder) RemoveTemp() error {
+	dir, err := f.fs.ReadDir(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, e := range dir {
+		if e.IsDir() || !fio.IsTempFile(e.Name()) {
+			continue
+		}
+		slog.Warn("removing file of an interrupted write",
+			slog.String("path", filepath.Join(f.path, e.Name())),
+		)
+		err = f.fs.Remove(filepath.Join(f.path, e.Name()))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Stem returns the name of a task file without its extension.
+func Stem(file string) string {
+	return strings.TrimSuffix(file, filepath.Ext(file))
+}
+
+// FileID returns the hex digits of the task id in the name of a task file.
+func FileID(file string) string {
+	stem := Stem(file)
+	return stem[strings.LastIndex(stem, "-")+1:]
+}
+
+// IsFileOf reports whether file is named as the task file of the task with
+// the given id. The id in the name stays the same when the file is renamed
+// after a change of title.
+func IsFileOf(file string, id uuid.UUID) bool {
+	fileID := FileID(file)
+	return len(fileID) >= shortID && strings.HasPrefix(strings.ReplaceAll(id.String(), "-", ""), fileID)
+}
+
+// Slug returns the part of the name of a task file that comes from its title.
+func Slug(file string) string {
+	stem := Stem(file)
+	if i := strings.LastIndex(stem, "-"); i >= 0 {
+		return stem[:i]
+	}
+	return stem
+}
+
+// parseOrder returns the ids listed in an order file. Lines that are not ids
+// are skipped.
+func parseOrder(content []byte) []uuid.UUID {
+	var ids []uuid.UUID
+	for _, line := range strings.Split(string(content), "\n") {
+		id, err := uuid.Parse(strings.TrimSpace(line))
+		if err != nil {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// MergeOrder merges the order files of a three-way merge: our order is kept
+// without the tasks they removed, and the tasks they added are placed after the
+// task they follow on their side.
+func MergeOrder(base, ours, theirs []byte) []byte {
+	b, o, t := parseOrder(base), parseOrder(ours), parseOrder(theirs)
+
+	var merged []uuid.UUID
+	for _, id := range o {
+		if slices.Contains(b, id) && !slices.Contains(t, id) {
+			continue
+		}
+		merged = append(merged, id)
+	}
+
+	for i, id := range t {
+		if slices.Contains(o, id) || slices.Contains(b, id) {
+			continue
+		}
+
+		at := 0
+		for j := i - 1; j >= 0; j-- {
+			if k := slices.Index(merged, t[j]); k >= 0 {
+				at = k + 1
+				break
+			}
+		}
+		merged = slices.Insert(merged, at, id)
+	}
+
+	return formatOrder(merged)
+}
+
+func formatOrder(ids []uuid.UUID) []byte {
+	var b []byte
+	buff := bytes.NewBuffer(b)
+	for _, id := range ids {
+		buff.WriteString(id.String())
+		buff.WriteByte('\n')
+	}
+	return buff.Bytes()
+}
+
+func tasksOf(entries []Entry) []*task.Task {
+	tasks := make([]*task.Task, 0, len(entries))
+	for _, e := range entries {
+		tasks = append(tasks, e.Task)
+	}
+	return tasks
+}
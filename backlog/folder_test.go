@@ -0,0 +1,178 @@
+package backlog
+
+import (
+	"github.com/google/uuid"
+	"github.com/tedla-brandsema/tribble/internal/fio"
+	"github.com/tedla-brandsema/tribble/task"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testFolder(t *testing.T, codec Codec) *Folder {
+	t.Helper()
+
+	dir := filepath.Join(t.TempDir(), "tasks")
+	return NewFolder(fio.Disk, dir, codec, func(title, id string) string {
+		return filepath.Join(dir, strings.ToLower(strings.ReplaceAll(title, " ", "-"))+"-"+id)
+	})
+}
+
+func newTask(t *testing.T, id, title string) *task.Task {
+	t.Helper()
+
+	tk, err := task.New(title, "")
+	if err != nil {
+		t.Fatalf("Failed to create task: %v", err)
+	}
+	tk.ID = uuid.MustParse(id)
+	return tk
+}
+
+func folderFiles(t *testing.T, f *Folder) []string {
+	t.Helper()
+
+	files, err := f.Files()
+	if err != nil {
+		t.Fatalf("Failed to list files: %v", err)
+	}
+	slices.Sort(files)
+	return files
+}
+
+func TestFolder(t *testing.T) {
+	for _, c := range codecs {
+		t.Run(c.name, func(t *testing.T) {
+			f := testFolder(t, c.codec)
+			first := newTask(t, "12345678-aaaa-4aaa-8aaa-aaaaaaaaaaaa", "Same")
+			second := newTask(t, "12345678-bbbb-4bbb-8bbb-bbbbbbbbbbbb", "Same")
+			third := newTask(t, "abcdef01-cccc-4ccc-8ccc-cccccccccccc", "Other task")
+
+			tasks := []*task.Task{third, first, second}
+			if err := f.Save(tasks); err != nil {
+				t.Fatalf("Failed to save tasks: %v", err)
+			}
+
+			ext := c.codec.Ext()
+			expected := []string{"other-task-abcdef01" + ext, "same-12345678" + ext, "same-12345678b" + ext}
+			if files := folderFiles(t, f); !slices.Equal(files, expected) {
+				t.Fatalf("Expected files %v, got %v", expected, files)
+			}
+
+			loaded, err := f.Load()
+			if err != nil {
+				t.Fatalf("Failed to load tasks: %v", err)
+			}
+			if len(loaded) != 3 || !loaded[0].Equal(third) || !loaded[1].Equal(first) || !loaded[2].Equal(second) {
+				t.Fatalf("Expected tasks %v, got %v", tasks, loaded)
+			}
+
+			if err = first.SetTitle("Renamed"); err != nil {
+				t.Fatalf("Failed to set title: %v", err)
+			}
+			if err = f.Save([]*task.Task{first, second}); err != nil {
+				t.Fatalf("Failed to save tasks: %v", err)
+			}
+
+			expected = []string{"renamed-12345678" + ext, "same-12345678b" + ext}
+			if files := folderFiles(t, f); !slices.Equal(files, expected) {
+				t.Fatalf("Expected files %v, got %v", expected, files)
+			}
+		})
+	}
+
+	t.Run("Interrupted rename", func(t *testing.T) {
+		f := testFolder(t, JSON)
+		tk := newTask(t, "12345678-aaaa-4aaa-8aaa-aaaaaaaaaaaa", "Old")
+		tk.Created = tk.Created.Add(-time.Hour)
+		tk.Modified = tk.Created
+		if err := f.Save([]*task.Task{tk}); err != nil {
+			t.Fatalf("Failed to save tasks: %v", err)
+		}
+		old, err := os.ReadFile(filepath.Join(f.Path(), "old-12345678.json"))
+		if err != nil {
+			t.Fatalf("Failed to read task file: %v", err)
+		}
+
+		// A rename writes the new file before it removes the old one.
+		if err = tk.SetTitle("Renamed"); err != nil {
+			t.Fatalf("Failed to set title: %v", err)
+		}
+		if err = f.Save([]*task.Task{tk}); err != nil {
+			t.Fatalf("Failed to save tasks: %v", err)
+		}
+		if err = os.WriteFile(filepath.Join(f.Path(), "old-12345678.json"), old, 0644); err != nil {
+			t.Fatalf("Failed to restore old task file: %v", err)
+		}
+
+		loaded, err := f.Load()
+		if err != nil {
+			t.Fatalf("Failed to load tasks: %v", err)
+		}
+		if len(loaded) != 1 || loaded[0].Title != "Renamed" {
+			t.Fatalf("Expected the renamed task once, got %v", loaded)
+		}
+
+		other := newTask(t, "22222222-bbbb-4bbb-8bbb-bbbbbbbbbbbb", "Other")
+		if err = f.Save(append(loaded, other)); err != nil {
+			t.Fatalf("Failed to save tasks: %v", err)
+		}
+		expected := []string{"other-22222222.json", "renamed-12345678.json"}
+		if files := folderFiles(t, f); !slices.Equal(files, expected) {
+			t.Fatalf("Expected files %v, got %v", expected, files)
+		}
+	})
+
+	t.Run("Unordered", func(t *testing.T) {
+		f := testFolder(t, JSON)
+		first := newTask(t, "11111111-aaaa-4aaa-8aaa-aaaaaaaaaaaa", "First")
+		second := newTask(t, "22222222-bbbb-4bbb-8bbb-bbbbbbbbbbbb", "Second")
+		if err := f.Save([]*task.Task{second, first}); err != nil {
+			t.Fatalf("Failed to save tasks: %v", err)
+		}
+		if err := os.Remove(filepath.Join(f.Path(), OrderFile)); err != nil {
+			t.Fatalf("Failed to remove order file: %v", err)
+		}
+
+		loaded, err := f.Load()
+		if err != nil {
+			t.Fatalf("Failed to load tasks: %v", err)
+		}
+		if len(loaded) != 2 || loaded[0].ID != first.ID {
+			t.Fatalf("Expected tasks in the order they were created, got %v", loaded)
+		}
+	})
+}
+
+func TestMergeOrder(t *testing.T) {
+	a, b, c, d := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	order := func(ids ...uuid.UUID) []byte {
+		return formatOrder(ids)
+	}
+
+	tests := []struct {
+		name     string
+		base     []byte
+		ours     []byte
+		theirs   []byte
+		expected []byte
+	}{
+		{name: "Unchanged", base: order(a, b), ours: order(a, b), theirs: order(a, b), expected: order(a, b)},
+		{name: "Added by both", base: order(a), ours: order(a, b), theirs: order(c, a), expected: order(c, a, b)},
+		{name: "Removed by them", base: order(a, b, c), ours: order(a, b, c), theirs: order(a, c), expected: order(a, c)},
+		{name: "Added after a task", base: order(a, b), ours: order(b, a), theirs: order(a, d, b), expected: order(b, a, d)},
+		{name: "No base", ours: order(a), theirs: order(b), expected: order(b, a)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			merged := MergeOrder(test.base, test.ours, test.theirs)
+			if string(merged) != string(test.expected) {
+				t.Fatalf("Expected order %q, got %q", test.expected, merged)
+			}
+		})
+	}
+}
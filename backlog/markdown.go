@@ -0,0 +1,263 @@
+package backlog
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/tedla-brandsema/tribble/task"
+	"github.com/tedla-brandsema/tribble/tmpl"
+	"io"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	backlogTemplate = "backlog.tmpl"
+	timeLayout      = time.RFC3339
+)
+
+var (
+	templates *template.Template
+
+	summaryPattern  = regexp.MustCompile(`^\s*[-*] \[([ xX])\] (.*?)\s*(?:<!--\s*([0-9a-fA-F-]{36})\s*-->)?\s*$`)
+	sectionPattern  = regexp.MustCompile(`^# \[([0-9a-fA-F-]{36})\]: (.*)$`)
+	metadataPattern = regexp.MustCompile(`^\* ([A-Za-z]+): (.*)$`)
+)
+
+func init() {
+	templates = loadTemplates()
+}
+
+func loadTemplates() *template.Template {
+	funcs := template.FuncMap{
+		"join":   strings.Join,
+		"escape": escape,
+		"date":   task.FormatDue,
+	}
+	return template.Must(template.New("").Funcs(funcs).ParseFS(tmpl.FileSystem(), "*.tmpl"))
+}
+
+// Render writes the tasks as a markdown backlog: a checklist summarising the
+// tasks in order, followed by one section per task holding its details.
+func Render(w io.Writer, tasks []*task.Task) error {
+	return templates.ExecuteTemplate(w, backlogTemplate, tasks)
+}
+
+type summary struct {
+	line  int
+	done  bool
+	title string
+	id    uuid.UUID
+}
+
+type section struct {
+	task        *task.Task
+	description []string
+	// metadata is set until the metadata block of the section ended, fields
+	// once it held a field.
+	metadata bool
+	fields   bool
+}
+
+// Parse reads a markdown backlog as written by Render.
+//
+// The task sections are authoritative for the content of a task, while the
+// checklist decides the order of the tasks and whether they are done. This
+// allows a backlog to be reordered and checked off in any editor. Checklist
+// items without an id are added as new tasks, and sections that are missing
+// from the checklist are appended in the order in which they appear.
+func Parse(r io.Reader) ([]*task.Task, error) {
+	var (
+		summaries []summary
+		sections  []*section
+		current   *section
+	)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1024*1024)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := scanner.Text()
+
+		if m := sectionPattern.FindStringSubmatch(text); m != nil {
+			id, err := uuid.Parse(m[1])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid task id: %w", line, err)
+			}
+			current = &section{
+				task:     &task.Task{ID: id, Title: strings.TrimSpace(m[2])},
+				metadata: true,
+			}
+			sections = append(sections, current)
+			continue
+		}
+
+		if current == nil {
+			m := summaryPattern.FindStringSubmatch(text)
+			if m == nil {
+				continue
+			}
+			s := summary{line: line, done: m[1] != " ", title: m[2]}
+			if m[3] != "" {
+				id, err := uuid.Parse(m[3])
+				if err != nil {
+					return nil, fmt.Errorf("line %d: invalid task id: %w", line, err)
+				}
+				s.id = id
+			}
+			summaries = append(summaries, s)
+			continue
+		}
+
+		if current.metadata {
+			// The metadata block ends at the first blank or other line after
+			// its fields, everything below it is description.
+			if strings.TrimSpace(text) == "" {
+				current.metadata = !current.fields
+				continue
+			}
+			if m := metadataPattern.FindStringSubmatch(text); m != nil {
+				if err := setMetadata(current.task, m[1], strings.TrimSpace(m[2])); err != nil {
+					return nil, fmt.Errorf("line %d: %w", line, err)
+				}
+				current.fields = true
+				continue
+			}
+			current.metadata = false
+		}
+		current.description = append(current.description, unescape(text))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return merge(summaries, sections)
+}
+
+// escape prefixes the lines of a description that would be read as a task
+// section or metadata with a backslash, which markdown shows as is.
+func escape(description string) string {
+	lines := strings.Split(description, "\n")
+	for i, line := range lines {
+		if escaped(line) {
+			lines[i] = `\` + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func unescape(line string) string {
+	if strings.HasPrefix(line, `\`) && escaped(line[1:]) {
+		return line[1:]
+	}
+	return line
+}
+
+// escaped reports whether escape prefixes line. Lines that only look like one
+// after removing a backslash are escaped as well, so every line survives a
+// round trip.
+func escaped(line string) bool {
+	if sectionPattern.MatchString(line) || metadataPattern.MatchString(line) {
+		return true
+	}
+	return strings.HasPrefix(line, `\`) && escaped(line[1:])
+}
+
+func setMetadata(t *task.Task, key, value string) error {
+	var err error
+
+	switch key {
+	case "Status":
+		t.Status = task.Status(value)
+	case "Tags":
+		t.Tags = task.ParseTags(value)
+	case "Priority":
+		t.Priority = task.Priority(value)
+	case "Due":
+		t.Due, err = task.ParseDue(value)
+	case "Created":
+		t.Created, err = time.Parse(timeLayout, value)
+	case "Modified":
+		t.Modified, err = time.Parse(timeLayout, value)
+	default:
+		return fmt.Errorf("unknown task field %q", key)
+	}
+
+	if err != nil {
+		return fmt.Errorf("invalid %s: %w", strings.ToLower(key), err)
+	}
+	return nil
+}
+
+func merge(summaries []summary, sections []*section) ([]*task.Task, error) {
+	byID := make(map[uuid.UUID]*task.Task, len(sections))
+	for _, s := range sections {
+		t := s.task
+		if _, ok := byID[t.ID]; ok {
+			return nil, fmt.Errorf("duplicate task %s", t.ID)
+		}
+		t.Description = strings.TrimSpace(strings.Join(s.description, "\n"))
+		if err := t.Validate(); err != nil {
+			return nil, fmt.Errorf("task %s: %w", t.ID, err)
+		}
+		byID[t.ID] = t
+	}
+
+	tasks := make([]*task.Task, 0, len(sections))
+	seen := make(map[uuid.UUID]bool, len(sections))
+
+	for _, s := range summaries {
+		if seen[s.id] {
+			return nil, fmt.Errorf("line %d: task %s is listed twice", s.line, s.id)
+		}
+
+		t, ok := byID[s.id]
+		switch {
+		case ok:
+		case s.id != uuid.Nil:
+			// The section was removed by hand: keep what the checklist tells us.
+			n, err := task.New(s.title, "")
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", s.line, err)
+			}
+			n.ID = s.id
+			t = n
+		default:
+			n, err := task.New(s.title, "")
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", s.line, err)
+			}
+			t = n
+		}
+
+		if err := applyCheckbox(t, s.done); err != nil {
+			return nil, fmt.Errorf("line %d: %w", s.line, err)
+		}
+
+		seen[t.ID] = true
+		tasks = append(tasks, t)
+	}
+
+	for _, s := range sections {
+		if !seen[s.task.ID] {
+			tasks = append(tasks, s.task)
+		}
+	}
+
+	return tasks, nil
+}
+
+func applyCheckbox(t *task.Task, done bool) error {
+	switch {
+	case done && !t.Done():
+		return t.SetStatus(task.StatusDone)
+	case !done && t.Done():
+		return t.SetStatus(task.StatusTodo)
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,192 @@
+package backlog
+
+import (
+	"bytes"
+	"github.com/google/uuid"
+	"github.com/tedla-brandsema/tribble/task"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testTasks(t *testing.T) []*task.Task {
+	t.Helper()
+
+	created := time.Date(2024, 10, 1, 12, 0, 0, 0, time.UTC)
+	return []*task.Task{
+		{
+			ID:          uuid.MustParse("0b6a3c35-4f57-4c2b-9f1a-6f3f0d6a8d01"),
+			Title:       "Write the parser",
+			Status:      task.StatusDone,
+			Tags:        []string{"parser", "v1"},
+			Priority:    task.PriorityHigh,
+			Due:         time.Date(2024, 11, 1, 0, 0, 0, 0, time.UTC),
+			Created:     created,
+			Modified:    created.Add(time.Hour),
+			Description: "Parse the backlog.\n\n* a bullet\n\nAnother paragraph.",
+		},
+		{
+			ID:       uuid.MustParse("7d1e0a8e-2c4b-4a53-8f6e-1f0f3b0c2e02"),
+			Title:    "Write the renderer",
+			Status:   task.StatusTodo,
+			Created:  created,
+			Modified: created,
+		},
+	}
+}
+
+func TestRenderAndParse(t *testing.T) {
+	tasks := testTasks(t)
+
+	var buff bytes.Buffer
+	if err := Render(&buff, tasks); err != nil {
+		t.Fatalf("Failed to render backlog: %v", err)
+	}
+
+	parsed, err := Parse(bytes.NewReader(buff.Bytes()))
+	if err != nil {
+		t.Fatalf("Failed to parse backlog: %v", err)
+	}
+
+	if !reflect.DeepEqual(parsed, tasks) {
+		t.Fatalf("Expected tasks %+v, got %+v", tasks, parsed)
+	}
+
+	var again bytes.Buffer
+	if err := Render(&again, parsed); err != nil {
+		t.Fatalf("Failed to render backlog: %v", err)
+	}
+	if again.String() != buff.String() {
+		t.Fatalf("Expected identical output, got:\n%s\nwant:\n%s", again.String(), buff.String())
+	}
+}
+
+func TestDescriptionLikeMetadata(t *testing.T) {
+	tasks := testTasks(t)
+	tasks[1].Description = strings.Join([]string{
+		"* Foo: bar",
+		"* Status: done",
+		"# [" + tasks[0].ID.String() + "]: x",
+		`\* Status: done`,
+		"",
+		"* Note: call Bob",
+	}, "\n")
+
+	for _, c := range codecs {
+		t.Run(c.name, func(t *testing.T) {
+			var buff bytes.Buffer
+			if err := c.codec.Encode(&buff, tasks); err != nil {
+				t.Fatalf("Failed to encode tasks: %v", err)
+			}
+
+			decoded, err := c.codec.Decode(&buff)
+			if err != nil {
+				t.Fatalf("Failed to decode tasks: %v", err)
+			}
+			if !reflect.DeepEqual(decoded, tasks) {
+				t.Fatalf("Expected tasks %+v, got %+v", tasks, decoded)
+			}
+		})
+	}
+
+	t.Run("Hand written", func(t *testing.T) {
+		content := "# [" + tasks[1].ID.String() + "]: Title\n" +
+			"\n" +
+			"* Status: todo\n" +
+			"* Created: 2024-10-01T12:00:00Z\n" +
+			"* Modified: 2024-10-01T12:00:00Z\n" +
+			"\n" +
+			"* Status: done\n" +
+			"* Note: call Bob\n"
+
+		parsed, err := Parse(strings.NewReader(content))
+		if err != nil {
+			t.Fatalf("Failed to parse backlog: %v", err)
+		}
+		if len(parsed) != 1 || parsed[0].Done() || parsed[0].Description != "* Status: done\n* Note: call Bob" {
+			t.Fatalf("Expected the lines after the metadata as description, got %+v", parsed)
+		}
+	})
+}
+
+func TestParseHandEdits(t *testing.T) {
+	tasks := testTasks(t)
+
+	var buff bytes.Buffer
+	if err := Render(&buff, tasks); err != nil {
+		t.Fatalf("Failed to render backlog: %v", err)
+	}
+	content := buff.String()
+
+	t.Run("Reordered and checked off", func(t *testing.T) {
+		lines := strings.Split(content, "\n")
+		// Swap the two checklist items and flip their checkboxes.
+		first := strings.Replace(lines[2], "[x]", "[ ]", 1)
+		second := strings.Replace(lines[3], "[ ]", "[x]", 1)
+		lines[2], lines[3] = second, first
+
+		parsed, err := Parse(strings.NewReader(strings.Join(lines, "\n")))
+		if err != nil {
+			t.Fatalf("Failed to parse backlog: %v", err)
+		}
+		if len(parsed) != 2 {
+			t.Fatalf("Expected 2 tasks, got %d", len(parsed))
+		}
+		if parsed[0].ID != tasks[1].ID || parsed[1].ID != tasks[0].ID {
+			t.Fatalf("Expected reversed order, got %s, %s", parsed[0].ID, parsed[1].ID)
+		}
+		if !parsed[0].Done() || parsed[1].Done() {
+			t.Fatalf("Expected flipped status, got %s, %s", parsed[0].Status, parsed[1].Status)
+		}
+		if parsed[1].Description != tasks[0].Description {
+			t.Fatalf("Expected description %q, got %q", tasks[0].Description, parsed[1].Description)
+		}
+	})
+
+	t.Run("New checklist item", func(t *testing.T) {
+		edited := strings.Replace(content, "\n\n# [", "\n- [x] Added by hand\n\n# [", 1)
+
+		parsed, err := Parse(strings.NewReader(edited))
+		if err != nil {
+			t.Fatalf("Failed to parse backlog: %v", err)
+		}
+		if len(parsed) != 3 {
+			t.Fatalf("Expected 3 tasks, got %d", len(parsed))
+		}
+		added := parsed[2]
+		if added.Title != "Added by hand" || !added.Done() || added.ID == uuid.Nil {
+			t.Fatalf("Expected new done task, got %+v", added)
+		}
+	})
+
+	t.Run("Section missing from checklist", func(t *testing.T) {
+		lines := strings.Split(content, "\n")
+		edited := strings.Join(append(lines[:3:3], lines[4:]...), "\n")
+
+		parsed, err := Parse(strings.NewReader(edited))
+		if err != nil {
+			t.Fatalf("Failed to parse backlog: %v", err)
+		}
+		if !reflect.DeepEqual(parsed, tasks) {
+			t.Fatalf("Expected tasks %+v, got %+v", tasks, parsed)
+		}
+	})
+
+	t.Run("Duplicate checklist item", func(t *testing.T) {
+		lines := strings.Split(content, "\n")
+		edited := strings.Join(append(lines[:3:3], lines[2:]...), "\n")
+
+		if _, err := Parse(strings.NewReader(edited)); err == nil {
+			t.Fatalf("Expected error, got none")
+		}
+	})
+
+	t.Run("Invalid timestamp", func(t *testing.T) {
+		edited := strings.Replace(content, "Created: 2024", "Created: yesterday 2024", 1)
+
+		if _, err := Parse(strings.NewReader(edited)); err == nil {
+			t.Fatalf("Expected error, got none")
+		}
+	})
+}
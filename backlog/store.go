@@ -0,0 +1,57 @@
+package backlog
+
+import (
+	"bytes"
+	"github.com/tedla-brandsema/tribble/internal/fio"
+	"github.com/tedla-brandsema/tribble/task"
+)
+
+// Store keeps a list of tasks in a single file, serialized by its Codec.
+type Store struct {
+	fs    fio.FS
+	path  string
+	codec Codec
+}
+
+func NewStore(fsys fio.FS, path string, codec Codec) *Store {
+	return &Store{
+		fs:    fsys,
+		path:  path,
+		codec: codec,
+	}
+}
+
+func (s *Store) Path() string {
+	return s.path
+}
+
+func (s *Store) Codec() Codec {
+	return s.codec
+}
+
+// Load reads all tasks from the store. A missing file is treated as an empty
+// list of tasks.
+func (s *Store) Load() ([]*task.Task, error) {
+	if !s.fs.FileExists(s.path) {
+		return nil, nil
+	}
+
+	content, err := s.fs.ReadFile(s.path)
+	if err != nil {
+		return nil, err
+	}
+	return s.codec.Decode(bytes.NewReader(content))
+}
+
+// Save replaces the stored tasks with the given tasks.
+func (s *Store) Save(tasks []*task.Task) error {
+	var b []byte
+	buff := bytes.NewBuffer(b)
+
+	err := s.codec.Encode(buff, tasks)
+	if err != nil {
+		return err
+	}
+
+	return s.fs.OverwriteFile(s.path, buff.Bytes())
+}
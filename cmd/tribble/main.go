@@ -0,0 +1,583 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/tedla-brandsema/tribble/config"
+	"github.com/tedla-brandsema/tribble/internal/fio"
+	"github.com/tedla-brandsema/tribble/internal/gui"
+	"github.com/tedla-brandsema/tribble/project"
+	"github.com/tedla-brandsema/tribble/repo"
+	"log/slog"
+	"maps"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"time"
+)
+
+type command struct {
+	name  string
+	usage string
+	run   func(args []string) error
+}
+
+// settings collects the repeatable -set flag, which overrides a setting for
+// one run of tribble.
+type settings []config.Option
+
+func (s *settings) String() string {
+	return ""
+}
+
+func (s *settings) Set(value string) error {
+	key, v, ok := strings.Cut(value, "=")
+	if !ok {
+		return fmt.Errorf("expected key=value, got %q", value)
+	}
+	*s = append(*s, config.Set(key, v))
+	return nil
+}
+
+// overrides holds the -set flags, they are passed to every config that is
+// loaded.
+var overrides settings
+
+var commands = []command{
+	{
+		name:  "migrate",
+		usage: "migrate <markdown|json|binary>\tconvert the stored tasks to another serialize mode",
+		run:   migrate,
+	},
+	{
+		name:  "recover",
+		usage: "recover [-truncate]\t\trepair a damaged binary task file",
+		run:   recoverTasks,
+	},
+	{
+		name:  "history",
+		usage: "history <task>\t\t\tshow who changed a task and when",
+		run:   history,
+	},
+	{
+		name:  "restore",
+		usage: "restore [-task <task>] <revision|time>\n\t\t\t\t\trestore the backlog or a single task to an earlier state",
+		run:   restore,
+	},
+	{
+		name:  "fetch",
+		usage: "fetch [remote]\t\t\tdownload the revisions of a remote",
+		run:   fetch,
+	},
+	{
+		name:  "pull",
+		usage: "pull [remote]\t\t\tmerge the tasks of a remote into the backlog",
+		run:   pull,
+	},
+	{
+		name:  "push",
+		usage: "push [remote]\t\t\tupload the backlog to a remote",
+		run:   push,
+	},
+	{
+		name:  "conflicts",
+		usage: "conflicts\t\t\tlist the task fields a merge could not combine",
+		run:   conflicts,
+	},
+	{
+		name:  "resolve",
+		usage: "resolve <task> <field> <ours|theirs|value>\n\t\t\t\t\tsettle a merge conflict",
+		run:   resolve,
+	},
+	{
+		name:  "workspace",
+		usage: "workspace [list|create <name> [revision]|switch <name>|merge <name>|delete <name>]\n\t\t\t\t\tmanage named backlogs",
+		run:   workspace,
+	},
+	{
+		name:  "config",
+		usage: "config\t\t\t\tshow the settings and where each was set",
+		run:   showConfig,
+	},
+	{
+		name:  "serve",
+		usage: "serve [-addr <address>]\t\tserve the web interface until interrupted",
+		run:   serve,
+	},
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Var(&overrides, "set", "override a setting for this run, as key=value")
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	for _, cmd := range commands {
+		if cmd.name != flag.Arg(0) {
+			continue
+		}
+		if err := cmd.run(flag.Args()[1:]); err != nil {
+			slog.Error(fmt.Sprintf("%s failed", cmd.name),
+				slog.Any("error", err),
+			)
+			os.Exit(1)
+		}
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "unknown command %q\n", flag.Arg(0))
+	usage()
+	os.Exit(2)
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: tribble [-set key=value]... <command> [arguments]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "flags:")
+	fmt.Fprintln(os.Stderr, "\t-set key=value\t\t\toverride a setting for this run, can be repeated")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "\t%s\n", cmd.usage)
+	}
+}
+
+func migrate(args []string) error {
+	if len(args) != 1 {
+		return errors.New("expected exactly one serialize mode")
+	}
+
+	mode, err := config.ParseSerializeMode(args[0])
+	if err != nil {
+		return err
+	}
+
+	p, err := project.Open("", overrides...)
+	if err != nil {
+		return err
+	}
+
+	from := p.Config().SerializeMode
+	err = p.Migrate(mode)
+	if err != nil {
+		return err
+	}
+
+	slog.Info("migrated tasks",
+		slog.String("from", from.String()),
+		slog.String("to", mode.String()),
+	)
+	return nil
+}
+
+func recoverTasks(args []string) error {
+	flags := flag.NewFlagSet("recover", flag.ContinueOnError)
+	truncate := flags.Bool("truncate", false, "drop everything from the first damaged byte instead of skipping damaged tasks")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	p, err := project.Open("", overrides...)
+	if err != nil {
+		return err
+	}
+
+	mode := fio.SkipCorrupt
+	if *truncate {
+		mode = fio.TruncateCorrupt
+	}
+
+	reports, err := p.Recover(mode)
+	if err != nil {
+		return err
+	}
+
+	var records int
+	var lostBytes int64
+	for _, file := range slices.Sorted(maps.Keys(reports)) {
+		report := reports[file]
+		for _, lost := range report.Lost {
+			slog.Warn("lost damaged data",
+				slog.String("file", file),
+				slog.Int64("offset", lost.Offset),
+				slog.Int64("bytes", lost.Length),
+				slog.Any("reason", lost.Reason),
+			)
+		}
+		records += len(report.Records)
+		lostBytes += report.LostBytes()
+	}
+	slog.Info("recovered tasks",
+		slog.Int("files", len(reports)),
+		slog.Int("tasks", records),
+		slog.Int64("lost_bytes", lostBytes),
+	)
+	return nil
+}
+
+// taskID returns the id of the task ref refers to, see project.Find. Tasks
+// that no longer exist can only be referred to by id.
+func taskID(p *project.Project, ref string) (uuid.UUID, error) {
+	if id, err := uuid.Parse(ref); err == nil {
+		return id, nil
+	}
+
+	t, err := p.Find(ref)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return t.ID, nil
+}
+
+func history(args []string) error {
+	if len(args) != 1 {
+		return errors.New("expected exactly one task")
+	}
+
+	p, err := project.Open("", overrides...)
+	if err != nil {
+		return err
+	}
+
+	id, err := taskID(p, args[0])
+	if err != nil {
+		return err
+	}
+
+	revisions, err := p.History(id)
+	if err != nil {
+		return err
+	}
+
+	for _, rev := range revisions {
+		fmt.Printf("%s %s %s <%s> %s\n", rev.Hash.String()[:8], rev.When.Format(time.RFC3339), rev.Author.Name, rev.Author.Email, rev.Action)
+		for _, change := range rev.Changes {
+			fmt.Printf("\t%s: %q -> %q\n", change.Field, change.Old, change.New)
+		}
+	}
+	return nil
+}
+
+func restore(args []string) error {
+	flags := flag.NewFlagSet("restore", flag.ContinueOnError)
+	ref := flags.String("task", "", "restore only this task, given by id or file name")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("expected exactly one revision or RFC 3339 time")
+	}
+
+	p, err := project.Open("", overrides...)
+	if err != nil {
+		return err
+	}
+
+	var rev repo.Revision
+	if when, err := time.Parse(time.RFC3339, flags.Arg(0)); err == nil {
+		rev, err = p.RevisionAt(when)
+		if err != nil {
+			return err
+		}
+	} else {
+		rev, err = p.Revision(flags.Arg(0))
+		if err != nil {
+			return err
+		}
+	}
+
+	if *ref == "" {
+		err = p.RestoreBacklog(rev)
+		if err != nil {
+			return err
+		}
+		slog.Info("restored backlog", slog.String("revision", rev.Hash.String()))
+		return nil
+	}
+
+	id, err := taskID(p, *ref)
+	if err != nil {
+		return err
+	}
+
+	t, err := p.RestoreTask(id, rev)
+	if err != nil {
+		return err
+	}
+	slog.Info("restored task",
+		slog.String("task", t.String()),
+		slog.String("revision", rev.Hash.String()),
+	)
+	return nil
+}
+
+func remoteArg(args []string) (string, error) {
+	switch len(args) {
+	case 0:
+		return project.DefaultRemote, nil
+	case 1:
+		return args[0], nil
+	default:
+		return "", errors.New("expected at most one remote")
+	}
+}
+
+func fetch(args []string) error {
+	remote, err := remoteArg(args)
+	if err != nil {
+		return err
+	}
+
+	p, err := project.Open("", overrides...)
+	if err != nil {
+		return err
+	}
+
+	err = p.Fetch(remote)
+	if err != nil {
+		return err
+	}
+	slog.Info("fetched", slog.String("remote", remote))
+	return nil
+}
+
+func pull(args []string) error {
+	remote, err := remoteArg(args)
+	if err != nil {
+		return err
+	}
+
+	p, err := project.Open("", overrides...)
+	if err != nil {
+		return err
+	}
+
+	result, err := p.Pull(remote)
+	if err != nil {
+		return err
+	}
+
+	switch result.Status {
+	case repo.UpToDate:
+		slog.Info("already up to date", slog.String("remote", remote))
+	case repo.FastForward:
+		slog.Info("fast-forwarded", slog.String("remote", remote), slog.String("revision", result.Hash.String()))
+	case repo.Merged:
+		slog.Info("merged", slog.String("remote", remote), slog.String("revision", result.Hash.String()))
+	}
+	return nil
+}
+
+func push(args []string) error {
+	remote, err := remoteArg(args)
+	if err != nil {
+		return err
+	}
+
+	p, err := project.Open("", overrides...)
+	if err != nil {
+		return err
+	}
+
+	err = p.Push(remote)
+	if err != nil {
+		return err
+	}
+	slog.Info("pushed", slog.String("remote", remote))
+	return nil
+}
+
+func conflicts(args []string) error {
+	if len(args) != 0 {
+		return errors.New("expected no arguments")
+	}
+
+	p, err := project.Open("", overrides...)
+	if err != nil {
+		return err
+	}
+
+	list, err := p.Conflicts()
+	if err != nil {
+		return err
+	}
+
+	for _, c := range list {
+		fmt.Printf("%s %s\n\tbase:   %q\n\tours:   %q\n\ttheirs: %q\n", c.TaskID, c.Field, c.Base, c.Ours, c.Theirs)
+	}
+	return nil
+}
+
+func resolve(args []string) error {
+	if len(args) != 3 {
+		return errors.New("expected a task, a field and a value")
+	}
+
+	p, err := project.Open("", overrides...)
+	if err != nil {
+		return err
+	}
+
+	id, err := taskID(p, args[0])
+	if err != nil {
+		return err
+	}
+
+	c, err := p.Conflict(id, args[1])
+	if err != nil {
+		return err
+	}
+
+	value := args[2]
+	switch value {
+	case "ours":
+		value = c.Ours
+	case "theirs":
+		value = c.Theirs
+	}
+
+	err = p.Resolve(id, c.Field, value)
+	if err != nil {
+		return err
+	}
+	slog.Info("resolved conflict",
+		slog.String("task", id.String()),
+		slog.String("field", c.Field),
+	)
+	return nil
+}
+
+func workspace(args []string) error {
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+
+	p, err := project.Open("", overrides...)
+	if err != nil {
+		return err
+	}
+
+	name := func() (string, error) {
+		if len(args) != 2 {
+			return "", fmt.Errorf("expected a workspace name after %s", args[0])
+		}
+		return args[1], nil
+	}
+
+	switch args[0] {
+	case "list":
+		workspaces, err := p.Workspaces()
+		if err != nil {
+			return err
+		}
+		for _, w := range workspaces {
+			marker := " "
+			if w.Current {
+				marker = "*"
+			}
+			fmt.Printf("%s %s\n", marker, w.Name)
+		}
+		return nil
+	case "create":
+		if len(args) < 2 || len(args) > 3 {
+			return errors.New("expected a workspace name and an optional revision")
+		}
+		var from string
+		if len(args) == 3 {
+			from = args[2]
+		}
+		w, err := p.CreateWorkspace(args[1], from)
+		if err != nil {
+			return err
+		}
+		slog.Info("created workspace", slog.String("workspace", w.Name), slog.String("revision", w.Head.String()))
+		return nil
+	case "switch":
+		n, err := name()
+		if err != nil {
+			return err
+		}
+		err = p.SwitchWorkspace(n)
+		if err != nil {
+			return err
+		}
+		slog.Info("switched workspace", slog.String("workspace", n))
+		return nil
+	case "merge":
+		n, err := name()
+		if err != nil {
+			return err
+		}
+		result, err := p.MergeWorkspace(n)
+		if err != nil {
+			return err
+		}
+		slog.Info("merged workspace", slog.String("workspace", n), slog.String("revision", result.Hash.String()))
+		return nil
+	case "delete":
+		n, err := name()
+		if err != nil {
+			return err
+		}
+		err = p.DeleteWorkspace(n)
+		if err != nil {
+			return err
+		}
+		slog.Info("deleted workspace", slog.String("workspace", n))
+		return nil
+	default:
+		return fmt.Errorf("unknown workspace command %q", args[0])
+	}
+}
+
+func showConfig(args []string) error {
+	if len(args) != 0 {
+		return errors.New("expected no arguments")
+	}
+
+	cfg, err := config.Load("", overrides...)
+	if err != nil {
+		return err
+	}
+
+	for _, v := range cfg.Values() {
+		source := v.Origin.Source.String()
+		if v.Origin.Name != "" {
+			source = fmt.Sprintf("%s %s", source, v.Origin.Name)
+		}
+		fmt.Printf("%s = %q\t(%s)\n", v.Key, v.Value, source)
+	}
+	return nil
+}
+
+func serve(args []string) error {
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	addr := flags.String("addr", "localhost:8080", "address to listen on")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	p, err := project.Open("", overrides...)
+	if err != nil {
+		return err
+	}
+	s, err := gui.NewServer(p)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), shutdownSignals...)
+	defer stop()
+	p.Watch(ctx, config.DefaultInterval)
+
+	slog.Info("serving", slog.String("addr", *addr))
+	return s.ListenAndServe(ctx, *addr)
+}
@@ -0,0 +1,12 @@
+//go:build !plan9
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// shutdownSignals stop serve gracefully: an interrupt from the terminal and
+// the SIGTERM service managers and containers stop processes with.
+var shutdownSignals = []os.Signal{os.Interrupt, syscall.SIGTERM}
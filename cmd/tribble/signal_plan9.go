@@ -0,0 +1,8 @@
+//go:build plan9
+
+package main
+
+import "os"
+
+// shutdownSignals stop serve gracefully. Plan 9 has no SIGTERM.
+var shutdownSignals = []os.Signal{os.Interrupt}
@@ -6,54 +6,25 @@ import (
 	"github.com/gosimple/slug"
 	"github.com/tedla-brandsema/tribble/internal/fio"
 	"log/slog"
-	"os"
 	"path/filepath"
+	"time"
 )
 
-var (
-	self *Config
-)
-
-func init() {
-	var err error
-
-	err = initFolders(tribblePath)
-	if err != nil {
-		msg := fmt.Sprintf("unable to create folder %s", rootFolder)
-		slog.Error(msg,
-			slog.Any("error", err),
-		)
-		os.Exit(1)
-		return
-	}
-
-	err = load()
-	if err != nil {
-		slog.Warn("unable to load config file",
-			slog.Any("error", err),
-		)
-		os.Exit(1)
-		return
-	}
-}
-
-func initFolders(paths ...string) error {
-	for _, path := range paths {
-		if !fio.FileExists(path) {
-			err := fio.MakeDir(path)
-			if err != nil {
-				return err
-			}
-		}
-	}
-	return nil
-}
-
 const (
-	rootFolder    = "."
 	tribbleFolder = ".tribble"
 	configFile    = "tribble.cfg"
+	lockFile      = "config.lock"
 	backlogFile   = "backlog.md"
+	// untitled names the files of tasks whose title has no letters or digits.
+	untitled = "task"
+
+	// TasksFolder is the folder in the tribble folder that holds the task
+	// files.
+	TasksFolder = "tasks"
+
+	// LockTimeout is how long tribble waits for another process to release
+	// one of the locks in the tribble folder.
+	LockTimeout = 5 * time.Second
 )
 
 type SerializeMode int
@@ -64,85 +35,207 @@ const (
 	SerializeBinary
 )
 
-// Internal vars
-var (
-	tribblePath = filepath.Join(rootFolder, tribbleFolder)
-	configPath  = filepath.Join(tribblePath, configFile)
-)
+var serializeModeNames = map[SerializeMode]string{
+	SerializeMarkdown: "markdown",
+	SerializeJSON:     "json",
+	SerializeBinary:   "binary",
+}
+
+func (m SerializeMode) String() string {
+	if name, ok := serializeModeNames[m]; ok {
+		return name
+	}
+	return fmt.Sprintf("SerializeMode(%d)", int(m))
+}
+
+// MarshalText stores the mode by name so tribble.cfg stays readable.
+func (m SerializeMode) MarshalText() ([]byte, error) {
+	name, ok := serializeModeNames[m]
+	if !ok {
+		return nil, fmt.Errorf("unknown serialize mode: %d", int(m))
+	}
+	return []byte(name), nil
+}
+
+func (m *SerializeMode) UnmarshalText(text []byte) error {
+	mode, err := ParseSerializeMode(string(text))
+	if err != nil {
+		return err
+	}
+	*m = mode
+	return nil
+}
+
+func ParseSerializeMode(name string) (SerializeMode, error) {
+	for mode, n := range serializeModeNames {
+		if n == name {
+			return mode, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown serialize mode: %q", name)
+}
 
 // CFG vars with defaults
 var (
 	serializeMode = SerializeMarkdown
-	backlogPath   = filepath.Join(rootFolder, backlogFile)
+	backlogPath   = backlogFile
+	author        = Author{Name: "tribble", Email: "tribble@localhost"}
 )
 
+// Author is recorded on the commits made for every change to the tasks.
+type Author struct {
+	Name  string
+	Email string
+}
+
+// Remote is a repository the tasks are shared through, usually a bare
+// repository all team members can reach.
+type Remote struct {
+	Name string
+	URL  string
+}
+
 type Config struct {
-	//SerializeMode SerializeMode
-	BacklogPath string
+	SerializeMode SerializeMode
+	BacklogPath   string
+	Author        Author
+	Remotes       []Remote `json:",omitempty"`
+	// Workspace is the workspace, a branch of the task repository, that is
+	// currently checked out.
+	Workspace string `json:",omitempty"`
+
+	root string
+	// fs holds the project files, see InMemory.
+	fs fio.FS
+	// origins holds where each setting was set; settings that are missing
+	// have their default. It is replaced, never changed, once the config is
+	// loaded, since configs are copied.
+	origins map[string]Origin
+	// project holds the settings of tribble.cfg as they were read or last
+	// written, whatever the layers after it override. It is replaced, never
+	// changed.
+	project *file
+	// loaded holds the values as they were after loading, to find the
+	// settings changed since.
+	loaded map[string]string
 }
 
-func NewDefaultConfig() *Config {
-	return &Config{
-		//SerializeMode: serializeMode,
-		BacklogPath: backlogPath,
+// Remote returns the remote with the given name.
+func (c *Config) Remote(name string) (Remote, bool) {
+	for _, r := range c.Remotes {
+		if r.Name == name {
+			return r, true
+		}
 	}
+	return Remote{}, false
 }
 
-func Get() *Config {
-	if self == nil {
-		_ = load()
+// NewDefaultConfig returns the default config of the project in root.
+func NewDefaultConfig(root string) *Config {
+	c := &Config{
+		SerializeMode: serializeMode,
+		BacklogPath:   backlogPath,
+		Author:        author,
+		root:          root,
+		fs:            fio.Disk,
+		origins:       make(map[string]Origin),
 	}
-
-	return self
+	c.snapshot()
+	return c
 }
 
-func load() error {
-	var err error
+// Root returns the project root the config was loaded from.
+func (c *Config) Root() string {
+	return c.root
+}
 
-	self, err = readConfig()
-	if err != nil {
-		return err
-	}
+// FS returns the file system that holds the files of the project.
+func (c *Config) FS() fio.FS {
+	return c.fs
+}
 
-	return nil
+// TribblePath returns the folder in which tribble keeps its own files.
+func (c *Config) TribblePath() string {
+	return filepath.Join(c.root, tribbleFolder)
 }
 
-func readConfig() (*Config, error) {
-	var cfg Config
+// Path returns the path of the config file.
+func (c *Config) Path() string {
+	return filepath.Join(c.TribblePath(), configFile)
+}
 
-	if !fio.FileExists(configPath) {
-		slog.Info("no config file found: creating config file")
-		err := writeConfig(NewDefaultConfig())
-		if err != nil {
-			return nil, err
-		}
-	}
+// TasksPath returns the folder that holds a file for every task.
+func (c *Config) TasksPath() string {
+	return filepath.Join(c.TribblePath(), TasksFolder)
+}
 
-	file, err := fio.ReadFile(configPath)
-	if err != nil {
-		return nil, err
+// TaskPath returns the path, without extension, of the file of a task with the
+// given title: the slug of the title followed by id.
+func (c *Config) TaskPath(title, id string) string {
+	path := filePath(c.TasksPath(), title)
+	if path == c.TasksPath() {
+		path = filePath(c.TasksPath(), untitled)
 	}
+	return path + "-" + id
+}
 
-	err = json.Unmarshal(file, &cfg)
-	if err != nil {
-		return nil, err
+// BacklogFile returns the path of the markdown backlog. A relative
+// BacklogPath is relative to the project root.
+func (c *Config) BacklogFile() string {
+	if filepath.IsAbs(c.BacklogPath) {
+		return c.BacklogPath
 	}
+	return filepath.Join(c.root, c.BacklogPath)
+}
 
-	return &cfg, nil
+func (c *Config) lockPath() string {
+	return filepath.Join(c.TribblePath(), lockFile)
 }
 
-func writeConfig(config *Config) error {
-	b, err := json.MarshalIndent(config, "", "\t")
+// Save writes the config to tribble.cfg. Only the settings read from
+// tribble.cfg or changed since loading are written, so values from the user
+// file, the environment and Set do not end up in the project.
+func (c *Config) Save() error {
+	err := c.Validate()
 	if err != nil {
 		return err
 	}
-	err = fio.OverwriteFile(configPath, b)
+
+	f := c.projectFile()
+	b, err := json.MarshalIndent(f, "", "\t")
 	if err != nil {
 		return err
 	}
+
+	err = c.withLock(fio.ExclusiveLock, func() error {
+		return c.fs.OverwriteFile(c.Path(), b)
+	})
+	if err != nil {
+		return classify(c.Path(), err)
+	}
+	c.saved(f)
 	return nil
 }
 
+// withLock runs fn while holding the config lock, so other tribble processes
+// never observe a config file that is being replaced.
+func (c *Config) withLock(mode fio.LockMode, fn func() error) error {
+	l, err := c.fs.AcquireLock(c.lockPath(), mode, LockTimeout)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := l.Release(); err != nil {
+			slog.Error("unable to release lock",
+				slog.String("path", l.Path()),
+				slog.Any("error", err),
+			)
+		}
+	}()
+
+	return fn()
+}
+
 func filePath(path, file string) string {
 	fileSlug := slug.Make(file)
 	return filepath.Join(path, fileSlug)
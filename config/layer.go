@@ -0,0 +1,401 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+const (
+	userFolder = "tribble"
+	envPrefix  = "TRIBBLE_"
+)
+
+// Source is the configuration layer an effective value came from. Later
+// layers override earlier ones.
+type Source int
+
+const (
+	SourceDefault Source = iota
+	SourceUser
+	SourceProject
+	SourceEnv
+	SourceOverride
+)
+
+var sourceNames = map[Source]string{
+	SourceDefault:  "default",
+	SourceUser:     "user",
+	SourceProject:  "project",
+	SourceEnv:      "env",
+	SourceOverride: "override",
+}
+
+func (s Source) String() string {
+	if name, ok := sourceNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("Source(%d)", int(s))
+}
+
+// Origin tells where a value was set: the layer and, for files and the
+// environment, the path or variable name.
+type Origin struct {
+	Source Source
+	Name   string
+}
+
+// Value is an effective setting and the place it was set.
+type Value struct {
+	Key    string
+	Value  string
+	Origin Origin
+}
+
+// Setting keys, as used by Set and reported by Values.
+const (
+	KeySerializeMode = "SerializeMode"
+	KeyBacklogPath   = "BacklogPath"
+	KeyAuthorName    = "Author.Name"
+	KeyAuthorEmail   = "Author.Email"
+	KeyRemotes       = "Remotes"
+)
+
+// KeyWorkspace is reported by Values but cannot be set: the workspace follows
+// the branch checked out in the task repository.
+const KeyWorkspace = "Workspace"
+
+var keys = []string{KeySerializeMode, KeyBacklogPath, KeyAuthorName, KeyAuthorEmail, KeyRemotes, KeyWorkspace}
+
+// setting is a value that can be set from the environment and by an
+// override. A project setting describes the stored tasks: the user file and the
+// environment cannot set it, and an override cannot change it once tribble.cfg
+// holds it.
+type setting struct {
+	key     string
+	set     func(c *Config, value string) error
+	project bool
+}
+
+var settings = []setting{
+	{
+		key:     KeySerializeMode,
+		project: true,
+		set: func(c *Config, value string) error {
+			mode, err := ParseSerializeMode(value)
+			if err != nil {
+				return err
+			}
+			c.SerializeMode = mode
+			return nil
+		},
+	},
+	{
+		key: KeyBacklogPath,
+		set: func(c *Config, value string) error {
+			c.BacklogPath = value
+			return nil
+		},
+	},
+	{
+		key: KeyAuthorName,
+		set: func(c *Config, value string) error {
+			c.Author.Name = value
+			return nil
+		},
+	},
+	{
+		key: KeyAuthorEmail,
+		set: func(c *Config, value string) error {
+			c.Author.Email = value
+			return nil
+		},
+	},
+	{
+		key: KeyRemotes,
+		set: func(c *Config, value string) error {
+			remotes, err := parseRemotes(value)
+			if err != nil {
+				return err
+			}
+			c.Remotes = remotes
+			return nil
+		},
+	},
+}
+
+// parseRemotes parses remotes in the form Values reports them:
+// name=url, separated by commas.
+func parseRemotes(value string) ([]Remote, error) {
+	var remotes []Remote
+	for _, r := range strings.Split(value, ",") {
+		r = strings.TrimSpace(r)
+		if r == "" {
+			continue
+		}
+		name, url, ok := strings.Cut(r, "=")
+		if !ok {
+			return nil, fmt.Errorf("expected name=url, got %q", r)
+		}
+		remotes = append(remotes, Remote{Name: strings.TrimSpace(name), URL: strings.TrimSpace(url)})
+	}
+	return remotes, nil
+}
+
+// envName returns the environment variable of a key: Author.Name is set by
+// TRIBBLE_AUTHOR_NAME.
+func envName(key string) string {
+	var b strings.Builder
+	b.WriteString(envPrefix)
+	for i, r := range key {
+		switch {
+		case r == '.':
+			b.WriteByte('_')
+			continue
+		case i > 0 && r >= 'A' && r <= 'Z' && key[i-1] != '.':
+			b.WriteByte('_')
+		}
+		b.WriteString(strings.ToUpper(string(r)))
+	}
+	return b.String()
+}
+
+// file is the layout of tribble.cfg and of the user config file. Settings that
+// are not in the file are left to the other layers.
+type file struct {
+	Version       int
+	SerializeMode *SerializeMode `json:",omitempty"`
+	BacklogPath   *string        `json:",omitempty"`
+	Author        *authorFile    `json:",omitempty"`
+	Remotes       *[]Remote      `json:",omitempty"`
+	Workspace     *string        `json:",omitempty"`
+}
+
+type authorFile struct {
+	Name  *string `json:",omitempty"`
+	Email *string `json:",omitempty"`
+}
+
+// UserPath returns the path of the per user config file, tribble/tribble.cfg
+// in the XDG config directory.
+func UserPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, userFolder, configFile), nil
+}
+
+// Set overrides a setting, given by one of the Key constants, after all other
+// layers have been applied.
+func Set(key, value string) Option {
+	return func(o *options) {
+		o.overrides = append(o.overrides, override{key: key, value: value})
+	}
+}
+
+type override struct {
+	key   string
+	value string
+}
+
+func findSetting(key string) (setting, bool) {
+	i := slices.IndexFunc(settings, func(s setting) bool { return s.key == key })
+	if i < 0 {
+		return setting{}, false
+	}
+	return settings[i], true
+}
+
+// applyFile applies the settings present in f.
+func (c *Config) applyFile(f *file, origin Origin) {
+	if f.SerializeMode != nil {
+		c.SerializeMode = *f.SerializeMode
+		c.origins[KeySerializeMode] = origin
+	}
+	if f.BacklogPath != nil {
+		c.BacklogPath = *f.BacklogPath
+		c.origins[KeyBacklogPath] = origin
+	}
+	if f.Author != nil && f.Author.Name != nil {
+		c.Author.Name = *f.Author.Name
+		c.origins[KeyAuthorName] = origin
+	}
+	if f.Author != nil && f.Author.Email != nil {
+		c.Author.Email = *f.Author.Email
+		c.origins[KeyAuthorEmail] = origin
+	}
+	if f.Remotes != nil {
+		c.Remotes = slices.Clone(*f.Remotes)
+		c.origins[KeyRemotes] = origin
+	}
+	if f.Workspace != nil {
+		c.Workspace = *f.Workspace
+		c.origins[KeyWorkspace] = origin
+	}
+}
+
+// applyEnv applies the TRIBBLE_* environment variables that are set.
+func (c *Config) applyEnv() error {
+	for _, s := range settings {
+		name := envName(s.key)
+		value, ok := os.LookupEnv(name)
+		if !ok || value == "" {
+			continue
+		}
+		if s.project {
+			return fmt.Errorf("%s: %w, use tribble migrate to change it", name, ErrProjectSetting)
+		}
+		if err := s.set(c, value); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		c.origins[s.key] = Origin{Source: SourceEnv, Name: name}
+	}
+	return nil
+}
+
+func (c *Config) applyOverrides(overrides []override) error {
+	for _, o := range overrides {
+		s, ok := findSetting(o.key)
+		if !ok {
+			return fmt.Errorf("%w %q", ErrUnknownSetting, o.key)
+		}
+		previous := c.value(o.key)
+		if err := s.set(c, o.value); err != nil {
+			return fmt.Errorf("%s: %w", o.key, err)
+		}
+		if s.project && c.Origin(o.key).Source == SourceProject {
+			if c.value(o.key) != previous {
+				return fmt.Errorf("%s: %w, use tribble migrate to change it", o.key, ErrProjectSetting)
+			}
+			continue
+		}
+		c.origins[o.key] = Origin{Source: SourceOverride}
+	}
+	return nil
+}
+
+// projectFile returns the settings to write to tribble.cfg: the settings read
+// from it, the settings changed since the config was loaded and the serialize
+// mode, which describes the tasks stored in the project and so must not depend
+// on the user or the environment. A value of tribble.cfg that another layer
+// overrides is written as it was read, so the override does not end up in the
+// project.
+func (c *Config) projectFile() *file {
+	f := c.project.clone()
+	f.Version = Version
+	f.SerializeMode = ptr(c.SerializeMode)
+	if c.changed(KeyBacklogPath) {
+		f.BacklogPath = ptr(c.BacklogPath)
+	}
+	if c.changed(KeyAuthorName) || c.changed(KeyAuthorEmail) {
+		if f.Author == nil {
+			f.Author = &authorFile{}
+		}
+		if c.changed(KeyAuthorName) {
+			f.Author.Name = ptr(c.Author.Name)
+		}
+		if c.changed(KeyAuthorEmail) {
+			f.Author.Email = ptr(c.Author.Email)
+		}
+	}
+	if c.changed(KeyRemotes) {
+		f.Remotes = ptr(slices.Clone(c.Remotes))
+	}
+	if c.changed(KeyWorkspace) {
+		f.Workspace = ptr(c.Workspace)
+	}
+	return f
+}
+
+// saved records that f was written to tribble.cfg: the values changed since
+// loading now come from the project file.
+func (c *Config) saved(f *file) {
+	origins := make(map[string]Origin, len(c.origins))
+	for key, origin := range c.origins {
+		origins[key] = origin
+	}
+	project := Origin{Source: SourceProject, Name: c.Path()}
+	origins[KeySerializeMode] = project
+	for _, key := range keys {
+		if c.changed(key) {
+			origins[key] = project
+		}
+	}
+	c.origins = origins
+	c.project = f
+	c.snapshot()
+}
+
+// changed reports whether a setting was changed since the config was loaded.
+func (c *Config) changed(key string) bool {
+	return c.value(key) != c.loaded[key]
+}
+
+// clone returns a copy of f that shares nothing with it. A nil f has no
+// settings.
+func (f *file) clone() *file {
+	if f == nil {
+		return &file{}
+	}
+	clone := *f
+	if f.Author != nil {
+		author := *f.Author
+		clone.Author = &author
+	}
+	if f.Remotes != nil {
+		clone.Remotes = ptr(slices.Clone(*f.Remotes))
+	}
+	return &clone
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+// snapshot remembers the current values to find the values changed later.
+func (c *Config) snapshot() {
+	c.loaded = make(map[string]string, len(keys))
+	for _, key := range keys {
+		c.loaded[key] = c.value(key)
+	}
+}
+
+func (c *Config) value(key string) string {
+	switch key {
+	case KeySerializeMode:
+		return c.SerializeMode.String()
+	case KeyBacklogPath:
+		return c.BacklogPath
+	case KeyAuthorName:
+		return c.Author.Name
+	case KeyAuthorEmail:
+		return c.Author.Email
+	case KeyRemotes:
+		remotes := make([]string, 0, len(c.Remotes))
+		for _, r := range c.Remotes {
+			remotes = append(remotes, r.Name+"="+r.URL)
+		}
+		return strings.Join(remotes, ", ")
+	case KeyWorkspace:
+		return c.Workspace
+	default:
+		return ""
+	}
+}
+
+// Origin returns where the effective value of a setting was set.
+func (c *Config) Origin(key string) Origin {
+	return c.origins[key]
+}
+
+// Values returns every effective setting with the place it was set.
+func (c *Config) Values() []Value {
+	values := make([]Value, 0, len(keys))
+	for _, key := range keys {
+		values = append(values, Value{Key: key, Value: c.value(key), Origin: c.Origin(key)})
+	}
+	return values
+}
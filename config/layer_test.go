@@ -0,0 +1,221 @@
+package config
+
+import (
+	"errors"
+	"github.com/tedla-brandsema/tribble/internal/testenv"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func writeUserFile(t *testing.T, content string) string {
+	t.Helper()
+
+	path, err := UserPath()
+	if err != nil {
+		t.Fatalf("Failed to find user config: %v", err)
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+	if err = os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write user config: %v", err)
+	}
+	return path
+}
+
+func TestLayers(t *testing.T) {
+	testenv.Isolate(t)
+
+	userPath := writeUserFile(t, `{"BacklogPath": "user.md", "Author": {"Name": "User", "Email": "user@example.com"}}`)
+
+	root := t.TempDir()
+	cfg, err := Load(root, Create(), Set(KeySerializeMode, "json"))
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	cfg.Author.Email = "project@example.com"
+	if err = cfg.Save(); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+
+	t.Setenv(envName(KeyAuthorName), "Env")
+	cfg, err = Load(root, Set(KeyBacklogPath, "override.md"), Set(KeySerializeMode, "json"))
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	tests := []struct {
+		key      string
+		expected string
+		origin   Origin
+	}{
+		{key: KeySerializeMode, expected: "json", origin: Origin{Source: SourceProject, Name: cfg.Path()}},
+		{key: KeyBacklogPath, expected: "override.md", origin: Origin{Source: SourceOverride}},
+		{key: KeyAuthorName, expected: "Env", origin: Origin{Source: SourceEnv, Name: "TRIBBLE_AUTHOR_NAME"}},
+		{key: KeyAuthorEmail, expected: "project@example.com", origin: Origin{Source: SourceProject, Name: cfg.Path()}},
+		{key: KeyRemotes, expected: "", origin: Origin{Source: SourceDefault}},
+	}
+
+	values := make(map[string]Value)
+	for _, v := range cfg.Values() {
+		values[v.Key] = v
+	}
+	for _, test := range tests {
+		t.Run(test.key, func(t *testing.T) {
+			v := values[test.key]
+			if v.Value != test.expected {
+				t.Fatalf("Expected value %q, got %q", test.expected, v.Value)
+			}
+			if v.Origin != test.origin {
+				t.Fatalf("Expected origin %+v, got %+v", test.origin, v.Origin)
+			}
+		})
+	}
+
+	t.Run("Save keeps layers out of the project", func(t *testing.T) {
+		if err := cfg.Save(); err != nil {
+			t.Fatalf("Failed to save config: %v", err)
+		}
+		t.Setenv(envName(KeyAuthorName), "")
+		if err := os.Remove(userPath); err != nil {
+			t.Fatalf("Failed to remove user config: %v", err)
+		}
+
+		loaded, err := Load(root)
+		if err != nil {
+			t.Fatalf("Failed to load config: %v", err)
+		}
+		if loaded.BacklogPath != backlogFile || loaded.Author.Name != author.Name {
+			t.Fatalf("Expected defaults for user and env settings, got %+v", loaded)
+		}
+		// The serialize mode describes the stored tasks and is always saved.
+		if loaded.SerializeMode != SerializeJSON {
+			t.Fatalf("Expected serialize mode %s, got %s", SerializeJSON, loaded.SerializeMode)
+		}
+	})
+
+	t.Run("Unknown setting", func(t *testing.T) {
+		if _, err := Load(root, Set("Colour", "blue")); !errors.Is(err, ErrUnknownSetting) {
+			t.Fatalf("Expected error %v, got %v", ErrUnknownSetting, err)
+		}
+	})
+
+	t.Run("Serialize mode is a project setting", func(t *testing.T) {
+		t.Run("Environment", func(t *testing.T) {
+			t.Setenv(envName(KeySerializeMode), "binary")
+			if _, err := Load(root); !errors.Is(err, ErrProjectSetting) {
+				t.Fatalf("Expected error %v, got %v", ErrProjectSetting, err)
+			}
+		})
+
+		t.Run("User file", func(t *testing.T) {
+			writeUserFile(t, `{"SerializeMode": "binary"}`)
+			defer writeUserFile(t, "{}")
+			if _, err := Load(root); !errors.Is(err, ErrProjectSetting) {
+				t.Fatalf("Expected error %v, got %v", ErrProjectSetting, err)
+			}
+		})
+
+		t.Run("Override", func(t *testing.T) {
+			if _, err := Load(root, Set(KeySerializeMode, "binary")); !errors.Is(err, ErrProjectSetting) {
+				t.Fatalf("Expected error %v, got %v", ErrProjectSetting, err)
+			}
+		})
+	})
+
+	t.Run("Corrupt user file", func(t *testing.T) {
+		writeUserFile(t, "{not json")
+		if _, err := Load(root); !errors.Is(err, ErrCorrupt) {
+			t.Fatalf("Expected error %v, got %v", ErrCorrupt, err)
+		}
+	})
+}
+
+func TestSaveKeepsOverriddenProjectValues(t *testing.T) {
+	testenv.Isolate(t)
+
+	root := t.TempDir()
+	cfg, err := Load(root, Create())
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	cfg.BacklogPath = "custom.md"
+	cfg.Author.Name = "Project"
+	if err = cfg.Save(); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+
+	t.Setenv(envName(KeyAuthorName), "Env")
+	cfg, err = Load(root, Set(KeyBacklogPath, "other.md"))
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	cfg.Author.Email = "project@example.com"
+	if err = cfg.Save(); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+	if cfg.BacklogPath != "other.md" || cfg.Origin(KeyBacklogPath).Source != SourceOverride {
+		t.Fatalf("Expected the override to stay in effect, got %q from %+v", cfg.BacklogPath, cfg.Origin(KeyBacklogPath))
+	}
+
+	t.Setenv(envName(KeyAuthorName), "")
+	loaded, err := Load(root)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	expected := Author{Name: "Project", Email: "project@example.com"}
+	if loaded.BacklogPath != "custom.md" || loaded.Author != expected {
+		t.Fatalf("Expected the project values, got %+v", loaded)
+	}
+}
+
+func TestSetRemotes(t *testing.T) {
+	testenv.Isolate(t)
+
+	root := t.TempDir()
+	if _, err := Load(root, Create()); err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	t.Run("Environment", func(t *testing.T) {
+		t.Setenv(envName(KeyRemotes), "origin=https://example.com/a.git, backup=/srv/b.git")
+		cfg, err := Load(root)
+		if err != nil {
+			t.Fatalf("Failed to load config: %v", err)
+		}
+		expected := []Remote{{Name: "origin", URL: "https://example.com/a.git"}, {Name: "backup", URL: "/srv/b.git"}}
+		if !slices.Equal(cfg.Remotes, expected) {
+			t.Fatalf("Expected remotes %+v, got %+v", expected, cfg.Remotes)
+		}
+		if origin := cfg.Origin(KeyRemotes); origin.Name != "TRIBBLE_REMOTES" {
+			t.Fatalf("Expected remotes from TRIBBLE_REMOTES, got %+v", origin)
+		}
+	})
+
+	t.Run("Override", func(t *testing.T) {
+		cfg, err := Load(root, Set(KeyRemotes, "origin=/srv/a.git"))
+		if err != nil {
+			t.Fatalf("Failed to load config: %v", err)
+		}
+		if v := cfg.Values(); !slices.Contains(v, Value{Key: KeyRemotes, Value: "origin=/srv/a.git", Origin: Origin{Source: SourceOverride}}) {
+			t.Fatalf("Expected the overridden remotes, got %+v", v)
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		if _, err := Load(root, Set(KeyRemotes, "origin")); err == nil {
+			t.Fatal("Expected an error for a remote without a URL")
+		}
+		if _, err := Load(root, Set(KeyRemotes, "=/srv/a.git")); !errors.Is(err, ErrInvalid) {
+			t.Fatalf("Expected error %v, got %v", ErrInvalid, err)
+		}
+	})
+
+	t.Run("Workspace cannot be set", func(t *testing.T) {
+		if _, err := Load(root, Set(KeyWorkspace, "feature")); !errors.Is(err, ErrUnknownSetting) {
+			t.Fatalf("Expected error %v, got %v", ErrUnknownSetting, err)
+		}
+	})
+}
@@ -0,0 +1,198 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/tedla-brandsema/tribble/internal/fio"
+	"io/fs"
+	"log/slog"
+	"path/filepath"
+)
+
+var (
+	// ErrMissing means the project has no tribble folder or config file.
+	ErrMissing = errors.New("config missing")
+	// ErrCorrupt means the config file could not be decoded.
+	ErrCorrupt = errors.New("config corrupt")
+	// ErrPermission means the config file or tribble folder is not accessible.
+	ErrPermission = errors.New("config not accessible")
+)
+
+// Error describes why a config could not be loaded or saved. Its Kind is one
+// of ErrMissing, ErrCorrupt, ErrPermission, ErrVersion or ErrInvalid, and
+// errors.Is matches both the kind and the underlying error.
+type Error struct {
+	Path string
+	Kind error
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s: %v", e.Path, e.Kind, e.Err)
+}
+
+func (e *Error) Unwrap() []error {
+	return []error{e.Kind, e.Err}
+}
+
+// classify wraps err in an Error of the matching kind. Errors of no known kind
+// are returned as they are.
+func classify(path string, err error) error {
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return &Error{Path: path, Kind: ErrMissing, Err: err}
+	case errors.Is(err, fs.ErrPermission):
+		return &Error{Path: path, Kind: ErrPermission, Err: err}
+	default:
+		return err
+	}
+}
+
+type options struct {
+	create    bool
+	fs        fio.FS
+	overrides []override
+}
+
+// Option changes how Load reads a config.
+type Option func(*options)
+
+// Create makes Load create the tribble folder and a default config file when
+// they are missing, instead of failing with ErrMissing.
+func Create() Option {
+	return func(o *options) {
+		o.create = true
+	}
+}
+
+// InMemory makes Load keep the project in memory, see WithFS. Nothing is read
+// from or written to the project root, which suits tests and scratch projects.
+func InMemory() Option {
+	return WithFS(fio.NewMemory(memfs.New()))
+}
+
+// WithFS makes Load read and write the project files, tribble.cfg included, on
+// fsys instead of the disk. The project opened with the config keeps its tasks,
+// backlog and repository on fsys as well. The user file is always read from
+// disk.
+func WithFS(fsys fio.FS) Option {
+	return func(o *options) {
+		o.fs = fsys
+	}
+}
+
+// Load reads the config of the project in root. An empty root is searched for
+// with FindRoot, starting in the current directory; if no project is found
+// and Create is given, the project is created in the current directory. Every
+// call returns a new Config, so configs of several projects can be used side
+// by side.
+//
+// The config is made of layers, each overriding the ones before it: the
+// defaults, the user file at UserPath, the project tribble.cfg, the TRIBBLE_*
+// environment variables and the settings given with Set. Config.Origin tells
+// which layer a value came from. The serialize mode is only taken from
+// tribble.cfg, or from Set for a new project, since it tells how the tasks of
+// the project are stored.
+func Load(root string, opts ...Option) (*Config, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	var err error
+	if root == "" {
+		root, err = FindRoot(".")
+		if errors.Is(err, ErrNoProject) && o.create {
+			root, err = ".", nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	root, err = filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := NewDefaultConfig(root)
+	if o.fs != nil {
+		cfg.fs = o.fs
+	}
+	exists := cfg.fs.FileExists(cfg.Path())
+	if !exists && !o.create {
+		return nil, &Error{Path: cfg.Path(), Kind: ErrMissing, Err: fs.ErrNotExist}
+	}
+
+	err = cfg.loadUser()
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		err = cfg.loadProject()
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = cfg.applyEnv()
+	if err != nil {
+		return nil, err
+	}
+	err = cfg.applyOverrides(o.overrides)
+	if err != nil {
+		return nil, err
+	}
+	cfg.snapshot()
+	err = cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	if !exists {
+		slog.Info("no config file found: creating config file",
+			slog.String("path", cfg.Path()),
+		)
+		err = cfg.fs.MakeDir(cfg.TribblePath())
+		if err != nil {
+			return nil, classify(cfg.TribblePath(), err)
+		}
+		err = cfg.Save()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return cfg, nil
+}
+
+// loadUser applies the user file. Not having one is fine.
+func (c *Config) loadUser() error {
+	path, err := UserPath()
+	if err != nil || !fio.FileExists(path) {
+		return nil
+	}
+
+	// The user file is not shared by several processes of one project, so
+	// it has no lock.
+	f, err := readFile(fio.Disk, path, func(_ fio.LockMode, fn func() error) error {
+		return fn()
+	})
+	if err != nil {
+		return err
+	}
+	if f.SerializeMode != nil {
+		return &Error{Path: path, Kind: ErrInvalid, Err: fmt.Errorf("%w: %s", ErrProjectSetting, KeySerializeMode)}
+	}
+	c.applyFile(f, Origin{Source: SourceUser, Name: path})
+	return nil
+}
+
+func (c *Config) loadProject() error {
+	f, err := readFile(c.fs, c.Path(), c.withLock)
+	if err != nil {
+		return err
+	}
+	c.applyFile(f, Origin{Source: SourceProject, Name: c.Path()})
+	c.project = f
+	return nil
+}
@@ -0,0 +1,132 @@
+package config
+
+import (
+	"errors"
+	"github.com/tedla-brandsema/tribble/internal/testenv"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	testenv.Isolate(t)
+
+	t.Run("Missing", func(t *testing.T) {
+		root := t.TempDir()
+
+		_, err := Load(root)
+		if !errors.Is(err, ErrMissing) {
+			t.Fatalf("Expected error %v, got %v", ErrMissing, err)
+		}
+		if _, err = os.Stat(filepath.Join(root, tribbleFolder)); !os.IsNotExist(err) {
+			t.Fatalf("Expected Load not to create the tribble folder")
+		}
+	})
+
+	t.Run("Create", func(t *testing.T) {
+		root := t.TempDir()
+
+		cfg, err := Load(root, Create())
+		if err != nil {
+			t.Fatalf("Failed to load config: %v", err)
+		}
+		if cfg.Root() != root {
+			t.Fatalf("Expected root %s, got %s", root, cfg.Root())
+		}
+		if _, err = os.Stat(cfg.Path()); err != nil {
+			t.Fatalf("Expected config file to be created: %v", err)
+		}
+		if cfg.BacklogFile() != filepath.Join(root, backlogFile) {
+			t.Fatalf("Expected backlog in %s, got %s", root, cfg.BacklogFile())
+		}
+	})
+
+	t.Run("Round trip", func(t *testing.T) {
+		root := t.TempDir()
+
+		cfg, err := Load(root, Create())
+		if err != nil {
+			t.Fatalf("Failed to load config: %v", err)
+		}
+		cfg.SerializeMode = SerializeBinary
+		cfg.Remotes = []Remote{{Name: "origin", URL: "/srv/tribble.git"}}
+		if err = cfg.Save(); err != nil {
+			t.Fatalf("Failed to save config: %v", err)
+		}
+
+		loaded, err := Load(root)
+		if err != nil {
+			t.Fatalf("Failed to load config: %v", err)
+		}
+		if loaded.SerializeMode != SerializeBinary {
+			t.Fatalf("Expected serialize mode %s, got %s", SerializeBinary, loaded.SerializeMode)
+		}
+		if r, ok := loaded.Remote("origin"); !ok || r.URL != "/srv/tribble.git" {
+			t.Fatalf("Expected remote origin, got %+v", loaded.Remotes)
+		}
+	})
+
+	t.Run("Corrupt", func(t *testing.T) {
+		root := t.TempDir()
+		cfg := NewDefaultConfig(root)
+		if err := os.MkdirAll(cfg.TribblePath(), 0755); err != nil {
+			t.Fatalf("Failed to create folder: %v", err)
+		}
+		if err := os.WriteFile(cfg.Path(), []byte("{not json"), 0644); err != nil {
+			t.Fatalf("Failed to write config: %v", err)
+		}
+
+		_, err := Load(root)
+		if !errors.Is(err, ErrCorrupt) {
+			t.Fatalf("Expected error %v, got %v", ErrCorrupt, err)
+		}
+		var cfgErr *Error
+		if !errors.As(err, &cfgErr) || cfgErr.Path != cfg.Path() {
+			t.Fatalf("Expected an error for %s, got %v", cfg.Path(), err)
+		}
+	})
+
+	t.Run("Permission", func(t *testing.T) {
+		if os.Geteuid() == 0 {
+			t.Skip("file permissions do not apply to root")
+		}
+
+		root := t.TempDir()
+		cfg, err := Load(root, Create())
+		if err != nil {
+			t.Fatalf("Failed to load config: %v", err)
+		}
+		if err = os.Chmod(cfg.Path(), 0); err != nil {
+			t.Fatalf("Failed to change mode: %v", err)
+		}
+
+		_, err = Load(root)
+		if !errors.Is(err, ErrPermission) {
+			t.Fatalf("Expected error %v, got %v", ErrPermission, err)
+		}
+	})
+
+	t.Run("Several projects", func(t *testing.T) {
+		a, err := Load(t.TempDir(), Create())
+		if err != nil {
+			t.Fatalf("Failed to load config: %v", err)
+		}
+		b, err := Load(t.TempDir(), Create())
+		if err != nil {
+			t.Fatalf("Failed to load config: %v", err)
+		}
+
+		a.SerializeMode = SerializeJSON
+		if err = a.Save(); err != nil {
+			t.Fatalf("Failed to save config: %v", err)
+		}
+
+		reloaded, err := Load(b.Root())
+		if err != nil {
+			t.Fatalf("Failed to load config: %v", err)
+		}
+		if reloaded.SerializeMode != SerializeMarkdown {
+			t.Fatalf("Expected the other project to be unchanged, got %s", reloaded.SerializeMode)
+		}
+	})
+}
@@ -0,0 +1,65 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"github.com/tedla-brandsema/tribble/internal/fio"
+	"os"
+	"path/filepath"
+)
+
+// EnvDir names the environment variable that sets the project root, skipping
+// the search for a tribble folder.
+const EnvDir = "TRIBBLE_DIR"
+
+var ErrNoProject = errors.New("no tribble project found")
+
+// FindRoot returns the project root for start: the directory named by
+// TRIBBLE_DIR if it is set, otherwise the nearest directory from start upwards
+// that holds .tribble/tribble.cfg. The search stops at the home directory and at
+// the root of the filesystem. If no project is found the error matches both
+// ErrMissing and ErrNoProject.
+func FindRoot(start string) (string, error) {
+	if dir := os.Getenv(EnvDir); dir != "" {
+		root, err := filepath.Abs(dir)
+		if err != nil {
+			return "", err
+		}
+		info, err := os.Stat(root)
+		if err != nil {
+			return "", classify(root, err)
+		}
+		if !info.IsDir() {
+			return "", fmt.Errorf("%s %s is not a directory", EnvDir, root)
+		}
+		return root, nil
+	}
+
+	if start == "" {
+		start = "."
+	}
+	start, err := filepath.Abs(start)
+	if err != nil {
+		return "", err
+	}
+	dir := start
+
+	home, err := os.UserHomeDir()
+	if err == nil {
+		home = filepath.Clean(home)
+	}
+
+	for {
+		if fio.FileExists(NewDefaultConfig(dir).Path()) {
+			return dir, nil
+		}
+
+		parent := filepath.Dir(dir)
+		if dir == home || parent == dir {
+			break
+		}
+		dir = parent
+	}
+
+	return "", &Error{Path: start, Kind: ErrMissing, Err: fmt.Errorf("%w in this or any parent directory", ErrNoProject)}
+}
@@ -0,0 +1,79 @@
+package config
+
+import (
+	"errors"
+	"github.com/tedla-brandsema/tribble/internal/testenv"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFindRoot(t *testing.T) {
+	testenv.Isolate(t)
+
+	top := t.TempDir()
+	project := filepath.Join(top, "project")
+	nested := filepath.Join(project, "a", "b")
+	home := filepath.Join(top, "home", "user")
+	for _, dir := range []string{nested, home} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+	}
+	// The project above home must not be found from inside home.
+	for _, dir := range []string{project, filepath.Dir(home)} {
+		if _, err := Load(dir, Create()); err != nil {
+			t.Fatalf("Failed to create project: %v", err)
+		}
+	}
+	t.Setenv(EnvDir, "")
+	t.Setenv("HOME", home)
+
+	tests := []struct {
+		name          string
+		start         string
+		expected      string
+		expectedError error
+	}{
+		{name: "Project root", start: project, expected: project},
+		{name: "Nested directory", start: nested, expected: project},
+		{name: "Outside any project", start: top, expectedError: ErrNoProject},
+		{name: "Stop at home", start: filepath.Join(home, "work"), expectedError: ErrMissing},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := os.MkdirAll(test.start, 0755); err != nil {
+				t.Fatalf("Failed to create dir: %v", err)
+			}
+
+			root, err := FindRoot(test.start)
+			if !errors.Is(err, test.expectedError) {
+				t.Fatalf("Expected error %v, got %v", test.expectedError, err)
+			}
+			if root != test.expected {
+				t.Fatalf("Expected root %q, got %q", test.expected, root)
+			}
+		})
+	}
+
+	t.Run("Environment override", func(t *testing.T) {
+		t.Setenv(EnvDir, project)
+
+		root, err := FindRoot(home)
+		if err != nil {
+			t.Fatalf("Failed to find root: %v", err)
+		}
+		if root != project {
+			t.Fatalf("Expected root %q, got %q", project, root)
+		}
+
+		cfg, err := Load("")
+		if err != nil {
+			t.Fatalf("Failed to load config: %v", err)
+		}
+		if cfg.BacklogFile() != filepath.Join(project, backlogFile) {
+			t.Fatalf("Expected backlog in %s, got %s", project, cfg.BacklogFile())
+		}
+	})
+}
@@ -0,0 +1,243 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/tedla-brandsema/tribble/internal/fio"
+	"log/slog"
+	"path/filepath"
+	"strings"
+)
+
+// Version is the layout version of the config files written by this tribble.
+// Files without a version predate versioning and have version 0.
+const Version = 1
+
+var (
+	// ErrVersion means the config file was written by a newer tribble.
+	ErrVersion = errors.New("config version not supported")
+	// ErrInvalid means a setting has a value tribble cannot use.
+	ErrInvalid = errors.New("config invalid")
+	// ErrUnknownSetting means the config names a setting tribble does not know.
+	ErrUnknownSetting = errors.New("unknown setting")
+	// ErrProjectSetting means a setting that describes the stored tasks, and so
+	// belongs to the project, was set by the user file or the environment.
+	ErrProjectSetting = errors.New("setting can only be set in the project config")
+)
+
+// migration upgrades a decoded config file from one version to the next.
+type migration func(m map[string]any) error
+
+// migrations holds the migration from version i to version i+1 at index i.
+// Add a migration, and raise Version, for every change to the layout of the
+// config files.
+var migrations = []migration{
+	migrateUnversioned,
+}
+
+// migrateUnversioned drops the empty author fields older versions wrote, which
+// would otherwise hide the author of other layers, and cleans the backlog path
+// the first versions wrote as ./backlog.md.
+func migrateUnversioned(m map[string]any) error {
+	if a, ok := m["Author"].(map[string]any); ok {
+		for _, key := range []string{"Name", "Email"} {
+			if a[key] == "" {
+				delete(a, key)
+			}
+		}
+		if len(a) == 0 {
+			delete(m, "Author")
+		}
+	}
+	if p, ok := m["BacklogPath"].(string); ok && p != "" {
+		m["BacklogPath"] = filepath.Clean(p)
+	}
+	return nil
+}
+
+// upgrade runs the migrations content needs to reach Version. It returns nil
+// if content is up to date.
+func upgrade(content []byte) ([]byte, error) {
+	m := make(map[string]any)
+	d := json.NewDecoder(bytes.NewReader(content))
+	d.UseNumber()
+	err := d.Decode(&m)
+	if err != nil {
+		return nil, err
+	}
+	// null decodes without error, but leaves no settings to migrate.
+	if m == nil {
+		return nil, errors.New("not a JSON object")
+	}
+
+	version := 0
+	if v, ok := m["Version"]; ok {
+		n, ok := v.(json.Number)
+		if !ok {
+			return nil, fmt.Errorf("version %v is not a number", v)
+		}
+		i, err := n.Int64()
+		if err != nil || i < 0 {
+			return nil, fmt.Errorf("invalid version %s", n)
+		}
+		version = int(i)
+	}
+	switch {
+	case version > Version:
+		return nil, fmt.Errorf("%w: version %d, this tribble reads up to version %d", ErrVersion, version, Version)
+	case version == Version:
+		return nil, nil
+	}
+
+	for i := version; i < Version; i++ {
+		err = migrations[i](m)
+		if err != nil {
+			return nil, fmt.Errorf("unable to migrate from version %d: %w", i, err)
+		}
+	}
+	m["Version"] = Version
+
+	return json.MarshalIndent(m, "", "\t")
+}
+
+// readFile reads the config file at path on fsys, migrating it in place if it
+// has an older version. The original is kept next to it as
+// <file>.v<version>.bak. lock guards the file: it is held shared to read and
+// exclusive to migrate.
+func readFile(fsys fio.FS, path string, lock func(fio.LockMode, func() error) error) (*file, error) {
+	var content []byte
+	read := func() error {
+		var err error
+		content, err = fsys.ReadFile(path)
+		return err
+	}
+	err := lock(fio.SharedLock, read)
+	if err != nil {
+		return nil, classify(path, err)
+	}
+
+	upgraded, err := upgrade(content)
+	if err != nil {
+		return nil, fileError(path, err)
+	}
+	if upgraded != nil {
+		err = lock(fio.ExclusiveLock, func() error {
+			// Another process may have migrated the file in the meantime.
+			if err := read(); err != nil {
+				return classify(path, err)
+			}
+			upgraded, err := upgrade(content)
+			if err != nil || upgraded == nil {
+				return fileError(path, err)
+			}
+			err = migrateFile(fsys, path, content, upgraded)
+			if err != nil {
+				return err
+			}
+			content = upgraded
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var f file
+	d := json.NewDecoder(bytes.NewReader(content))
+	d.DisallowUnknownFields()
+	err = d.Decode(&f)
+	if err != nil {
+		// The decoder has no typed error for unknown fields.
+		if name, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+			err = fmt.Errorf("%w %s", ErrUnknownSetting, name)
+		}
+		return nil, fileError(path, err)
+	}
+	return &f, nil
+}
+
+// fileError wraps an error decoding the file at path.
+func fileError(path string, err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, ErrVersion):
+		return &Error{Path: path, Kind: ErrVersion, Err: err}
+	default:
+		return &Error{Path: path, Kind: ErrCorrupt, Err: err}
+	}
+}
+
+func migrateFile(fsys fio.FS, path string, content, upgraded []byte) error {
+	var old struct{ Version int }
+	_ = json.Unmarshal(content, &old)
+	backup := fmt.Sprintf("%s.v%d.bak", path, old.Version)
+
+	slog.Info("migrating config file",
+		slog.String("path", path),
+		slog.Int("from", old.Version),
+		slog.Int("to", Version),
+		slog.String("backup", backup),
+	)
+	err := fsys.OverwriteFile(backup, content)
+	if err != nil {
+		return classify(backup, err)
+	}
+	err = fsys.OverwriteFile(path, upgraded)
+	if err != nil {
+		return classify(path, err)
+	}
+	return nil
+}
+
+// Validate reports the first setting that has a value tribble cannot use.
+func (c *Config) Validate() error {
+	if _, ok := serializeModeNames[c.SerializeMode]; !ok {
+		return c.invalid(KeySerializeMode, "unknown serialize mode %d", int(c.SerializeMode))
+	}
+
+	if strings.TrimSpace(c.BacklogPath) == "" {
+		return c.invalid(KeyBacklogPath, "empty path")
+	}
+	if strings.HasSuffix(c.BacklogPath, "/") || strings.HasSuffix(c.BacklogPath, string(filepath.Separator)) {
+		return c.invalid(KeyBacklogPath, "%q is a directory", c.BacklogPath)
+	}
+	if rel, err := filepath.Rel(c.TribblePath(), c.BacklogFile()); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return c.invalid(KeyBacklogPath, "%q is inside the %s folder", c.BacklogPath, tribbleFolder)
+	}
+
+	if strings.TrimSpace(c.Author.Name) == "" {
+		return c.invalid(KeyAuthorName, "empty name")
+	}
+	if strings.TrimSpace(c.Author.Email) == "" {
+		return c.invalid(KeyAuthorEmail, "empty email")
+	}
+
+	names := make(map[string]bool, len(c.Remotes))
+	for _, r := range c.Remotes {
+		switch {
+		case r.Name == "":
+			return c.invalid(KeyRemotes, "remote without a name")
+		case r.URL == "":
+			return c.invalid(KeyRemotes, "remote %q without a URL", r.Name)
+		case names[r.Name]:
+			return c.invalid(KeyRemotes, "remote %q given twice", r.Name)
+		}
+		names[r.Name] = true
+	}
+
+	return nil
+}
+
+// invalid returns an Error for a setting, naming the file or variable it was
+// set in.
+func (c *Config) invalid(key, format string, args ...any) error {
+	origin := c.Origin(key)
+	path := origin.Name
+	if path == "" {
+		path = origin.Source.String()
+	}
+	return &Error{Path: path, Kind: ErrInvalid, Err: fmt.Errorf("%s: %s", key, fmt.Sprintf(format, args...))}
+}
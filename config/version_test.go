@@ -0,0 +1,112 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/tedla-brandsema/tribble/internal/testenv"
+	"os"
+	"testing"
+)
+
+func writeProjectFile(t *testing.T, content string) *Config {
+	t.Helper()
+
+	cfg := NewDefaultConfig(t.TempDir())
+	if err := os.MkdirAll(cfg.TribblePath(), 0755); err != nil {
+		t.Fatalf("Failed to create folder: %v", err)
+	}
+	if err := os.WriteFile(cfg.Path(), []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	return cfg
+}
+
+func TestMigrate(t *testing.T) {
+	testenv.Isolate(t)
+
+	original := `{"SerializeMode": "json", "BacklogPath": "./docs/backlog.md", "Author": {"Name": "", "Email": ""}}`
+	cfg := writeProjectFile(t, original)
+
+	loaded, err := Load(cfg.Root())
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if loaded.SerializeMode != SerializeJSON || loaded.BacklogPath != "docs/backlog.md" {
+		t.Fatalf("Expected the settings of the file, got %+v", loaded)
+	}
+	if loaded.Author != author || loaded.Origin(KeyAuthorName).Source != SourceDefault {
+		t.Fatalf("Expected the default author, got %+v", loaded.Author)
+	}
+
+	backup, err := os.ReadFile(cfg.Path() + ".v0.bak")
+	if err != nil {
+		t.Fatalf("Failed to read backup: %v", err)
+	}
+	if string(backup) != original {
+		t.Fatalf("Expected backup %s, got %s", original, backup)
+	}
+
+	content, err := os.ReadFile(cfg.Path())
+	if err != nil {
+		t.Fatalf("Failed to read config: %v", err)
+	}
+	var migrated map[string]any
+	if err = json.Unmarshal(content, &migrated); err != nil {
+		t.Fatalf("Failed to decode config: %v", err)
+	}
+	if migrated["Version"] != float64(Version) {
+		t.Fatalf("Expected version %d, got %v", Version, migrated["Version"])
+	}
+	if _, ok := migrated["Author"]; ok {
+		t.Fatalf("Expected the empty author to be dropped, got %s", content)
+	}
+}
+
+func TestStrictDecoding(t *testing.T) {
+	testenv.Isolate(t)
+
+	tests := []struct {
+		name          string
+		content       string
+		expectedError error
+	}{
+		{name: "Valid", content: `{"Version": 1, "SerializeMode": "binary"}`},
+		{name: "Null", content: `null`, expectedError: ErrCorrupt},
+		{name: "Unknown key", content: `{"Version": 1, "Colour": "blue"}`, expectedError: ErrUnknownSetting},
+		{name: "Unknown nested key", content: `{"Version": 1, "Author": {"Nick": "t"}}`, expectedError: ErrCorrupt},
+		{name: "Newer version", content: `{"Version": 99}`, expectedError: ErrVersion},
+		{name: "Invalid version", content: `{"Version": "one"}`, expectedError: ErrCorrupt},
+		{name: "Unknown serialize mode", content: `{"Version": 1, "SerializeMode": "yaml"}`, expectedError: ErrCorrupt},
+		{name: "Empty backlog path", content: `{"Version": 1, "BacklogPath": ""}`, expectedError: ErrInvalid},
+		{name: "Backlog path in tribble folder", content: `{"Version": 1, "BacklogPath": ".tribble/backlog.md"}`, expectedError: ErrInvalid},
+		{name: "Backlog path is a directory", content: `{"Version": 1, "BacklogPath": "docs/"}`, expectedError: ErrInvalid},
+		{name: "Empty author name", content: `{"Version": 1, "Author": {"Name": " "}}`, expectedError: ErrInvalid},
+		{name: "Remote without URL", content: `{"Version": 1, "Remotes": [{"Name": "origin"}]}`, expectedError: ErrInvalid},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := writeProjectFile(t, test.content)
+
+			_, err := Load(cfg.Root())
+			if !errors.Is(err, test.expectedError) {
+				t.Fatalf("Expected error %v, got %v", test.expectedError, err)
+			}
+			var cfgErr *Error
+			if err != nil && (!errors.As(err, &cfgErr) || cfgErr.Path != cfg.Path()) {
+				t.Fatalf("Expected an error for %s, got %v", cfg.Path(), err)
+			}
+		})
+	}
+
+	t.Run("Save", func(t *testing.T) {
+		cfg, err := Load(t.TempDir(), Create())
+		if err != nil {
+			t.Fatalf("Failed to load config: %v", err)
+		}
+		cfg.BacklogPath = ""
+		if err = cfg.Save(); !errors.Is(err, ErrInvalid) {
+			t.Fatalf("Expected error %v, got %v", ErrInvalid, err)
+		}
+	})
+}
@@ -0,0 +1,196 @@
+package config
+
+import (
+	"context"
+	"github.com/tedla-brandsema/tribble/internal/fio"
+	"log/slog"
+	"maps"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultInterval is how often a Watcher looks for changed config files.
+const DefaultInterval = 2 * time.Second
+
+// Change is sent to subscribers when the config files changed. Config is the
+// active config; if the files could not be loaded Err tells why and Config is
+// the config that was active before.
+type Change struct {
+	Config   *Config
+	Previous *Config
+	Err      error
+}
+
+// Watcher keeps a config up to date with its files. It polls tribble.cfg and
+// the user file, so it works on every platform without file notifications,
+// and loads the config again when one of them changed. A config that does not
+// load, for example because it is half written or invalid, is reported and
+// the active config stays in use.
+type Watcher struct {
+	root string
+	fs   fio.FS
+	opts []Option
+
+	current atomic.Pointer[Config]
+
+	mux   sync.Mutex
+	files map[string]string
+
+	subsMux sync.Mutex
+	subs    map[int]func(Change)
+	next    int
+}
+
+// NewWatcher returns a Watcher for cfg. The options are used to load the
+// config again, and should be the options cfg was loaded with. The config is
+// always loaded from the file system of cfg.
+func NewWatcher(cfg *Config, opts ...Option) *Watcher {
+	w := &Watcher{
+		root: cfg.Root(),
+		fs:   cfg.FS(),
+		opts: append(slices.Clip(opts), WithFS(cfg.FS())),
+		subs: make(map[int]func(Change)),
+	}
+	w.current.Store(cfg)
+	w.files = w.read()
+	return w
+}
+
+// Config returns the active config. It must not be changed, since other
+// goroutines may be reading it; copy it instead.
+func (w *Watcher) Config() *Config {
+	return w.current.Load()
+}
+
+// Subscribe calls fn with every change until the returned function is
+// called. fn is called from the goroutine that found the change and should
+// return quickly.
+func (w *Watcher) Subscribe(fn func(Change)) (unsubscribe func()) {
+	w.subsMux.Lock()
+	defer w.subsMux.Unlock()
+
+	id := w.next
+	w.next++
+	w.subs[id] = fn
+	return func() {
+		w.subsMux.Lock()
+		defer w.subsMux.Unlock()
+		delete(w.subs, id)
+	}
+}
+
+// Changes returns a channel that receives every change until ctx is done. A
+// change that is not received before the next one is dropped, so a slow
+// reader only misses the changes that were already replaced. The channel is
+// closed once ctx is done, so it can be ranged over.
+func (w *Watcher) Changes(ctx context.Context) <-chan Change {
+	ch := make(chan Change, 1)
+
+	// mux keeps a change from being sent while the channel is closed:
+	// notify may still call fn after unsubscribe returned.
+	var mux sync.Mutex
+	closed := false
+	unsubscribe := w.Subscribe(func(c Change) {
+		mux.Lock()
+		defer mux.Unlock()
+		if closed {
+			return
+		}
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- c
+	})
+	go func() {
+		<-ctx.Done()
+		unsubscribe()
+
+		mux.Lock()
+		defer mux.Unlock()
+		closed = true
+		close(ch)
+	}()
+	return ch
+}
+
+// Run checks the config files every interval until ctx is done.
+func (w *Watcher) Run(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			w.Check()
+		}
+	}
+}
+
+// Check loads the config again if its files changed since the last check and
+// reports whether the active config changed.
+func (w *Watcher) Check() bool {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+
+	files := w.read()
+	if maps.Equal(files, w.files) {
+		return false
+	}
+	w.files = files
+
+	previous := w.current.Load()
+	cfg, err := Load(w.root, w.opts...)
+	if err != nil {
+		slog.Warn("unable to reload config",
+			slog.String("path", previous.Path()),
+			slog.Any("error", err),
+		)
+		w.notify(Change{Config: previous, Previous: previous, Err: err})
+		return false
+	}
+	// Saving the config changes the file but not the settings.
+	if slices.Equal(cfg.Values(), previous.Values()) {
+		return false
+	}
+
+	w.current.Store(cfg)
+	w.notify(Change{Config: cfg, Previous: previous})
+	return true
+}
+
+func (w *Watcher) notify(c Change) {
+	w.subsMux.Lock()
+	subs := make([]func(Change), 0, len(w.subs))
+	for _, id := range slices.Sorted(maps.Keys(w.subs)) {
+		subs = append(subs, w.subs[id])
+	}
+	w.subsMux.Unlock()
+
+	for _, fn := range subs {
+		fn(c)
+	}
+}
+
+// read returns the content of the config files. The files are small, so
+// comparing their content is cheaper than missing a change that kept the
+// size and modification time.
+func (w *Watcher) read() map[string]string {
+	files := make(map[string]string, 2)
+	read := func(fsys fio.FS, path string) {
+		content, err := fsys.ReadFile(path)
+		if err == nil {
+			files[path] = string(content)
+		}
+	}
+
+	read(w.fs, NewDefaultConfig(w.root).Path())
+	if path, err := UserPath(); err == nil {
+		read(fio.Disk, path)
+	}
+	return files
+}
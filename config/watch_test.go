@@ -0,0 +1,117 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"github.com/tedla-brandsema/tribble/internal/testenv"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestWatcher(t *testing.T) {
+	testenv.Isolate(t)
+
+	cfg, err := Load(t.TempDir(), Create())
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	w := NewWatcher(cfg)
+
+	var changes []Change
+	unsubscribe := w.Subscribe(func(c Change) {
+		changes = append(changes, c)
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch := w.Changes(ctx)
+
+	t.Run("Unchanged", func(t *testing.T) {
+		if w.Check() || len(changes) != 0 {
+			t.Fatalf("Expected no change, got %+v", changes)
+		}
+	})
+
+	t.Run("Saved without changes", func(t *testing.T) {
+		if err := cfg.Save(); err != nil {
+			t.Fatalf("Failed to save config: %v", err)
+		}
+		if w.Check() || len(changes) != 0 {
+			t.Fatalf("Expected no change, got %+v", changes)
+		}
+	})
+
+	t.Run("Changed", func(t *testing.T) {
+		changed := *cfg
+		changed.BacklogPath = "docs/backlog.md"
+		if err := changed.Save(); err != nil {
+			t.Fatalf("Failed to save config: %v", err)
+		}
+
+		if !w.Check() {
+			t.Fatalf("Expected a change")
+		}
+		if w.Config().BacklogPath != "docs/backlog.md" {
+			t.Fatalf("Expected the changed config, got %+v", w.Config())
+		}
+		if len(changes) != 1 || changes[0].Previous != cfg || changes[0].Config != w.Config() {
+			t.Fatalf("Expected one change from the original config, got %+v", changes)
+		}
+		select {
+		case c := <-ch:
+			if c.Config != w.Config() {
+				t.Fatalf("Expected the changed config on the channel, got %+v", c.Config)
+			}
+		default:
+			t.Fatalf("Expected a change on the channel")
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		active := w.Config()
+		if err := os.WriteFile(cfg.Path(), []byte(`{"Version": 1, "BacklogPath": ""}`), 0644); err != nil {
+			t.Fatalf("Failed to write config: %v", err)
+		}
+
+		if w.Check() {
+			t.Fatalf("Expected the invalid config not to be used")
+		}
+		if w.Config() != active {
+			t.Fatalf("Expected the active config to stay in use")
+		}
+		last := changes[len(changes)-1]
+		if !errors.Is(last.Err, ErrInvalid) || last.Config != active {
+			t.Fatalf("Expected error %v with the active config, got %+v", ErrInvalid, last)
+		}
+	})
+
+	t.Run("Changes closed", func(t *testing.T) {
+		cancel()
+
+		timeout := time.After(time.Second)
+		for {
+			select {
+			case _, ok := <-ch:
+				if !ok {
+					return
+				}
+			case <-timeout:
+				t.Fatalf("Expected the channel to be closed once the context is done")
+			}
+		}
+	})
+
+	t.Run("Unsubscribe", func(t *testing.T) {
+		unsubscribe()
+		n := len(changes)
+		if err := cfg.Save(); err != nil {
+			t.Fatalf("Failed to save config: %v", err)
+		}
+		if !w.Check() {
+			t.Fatalf("Expected a change")
+		}
+		if len(changes) != n {
+			t.Fatalf("Expected no calls after unsubscribing, got %+v", changes[n:])
+		}
+	})
+}
@@ -9,6 +9,8 @@ import (
 	"log/slog"
 	"math"
 	"os"
+	"path/filepath"
+	"strings"
 )
 
 func MakeDir(path string) error {
@@ -46,26 +48,104 @@ func AppendFile(path string, b []byte) error {
 	return err
 }
 
+// OverwriteFile replaces the contents of the file at path. The file is replaced
+// atomically, see WriteFileAtomic.
 func OverwriteFile(path string, b []byte) error {
+	return WriteFileAtomic(path, b)
+}
+
+// tempSuffix follows the name of the file in the name of the temporary file
+// WriteFileAtomic writes to, which starts with a dot.
+const tempSuffix = ".tmp-"
+
+// IsTempFile reports whether name is the name of a temporary file of
+// WriteFileAtomic. Such files are left behind when a write is interrupted.
+func IsTempFile(name string) bool {
+	return strings.HasPrefix(name, ".") && strings.Contains(name, tempSuffix)
+}
+
+// Indirections that allow tests to simulate a crash at every step of an
+// atomic write.
+var (
+	writeTemp  = func(f *os.File, b []byte) (int, error) { return f.Write(b) }
+	syncFile   = func(f *os.File) error { return f.Sync() }
+	renameFile = os.Rename
+)
+
+// WriteFileAtomic replaces the file at path so that readers, and the file after
+// a crash, see either the old or the new contents but never a partial write.
+//
+// The data is written to a temporary file in the same directory, synced to
+// disk and renamed over path, after which the directory itself is synced so
+// the rename survives a crash as well. The mode of an existing file is kept.
+func WriteFileAtomic(path string, b []byte) error {
 	var err error
 
-	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	dir := filepath.Dir(path)
+	perm := os.FileMode(0644)
+	if info, statErr := os.Stat(path); statErr == nil {
+		perm = info.Mode().Perm()
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+tempSuffix+"*")
 	if err != nil {
 		return err
 	}
+	tmpPath := tmp.Name()
+
+	closed := false
 	defer func() {
-		if err = file.Close(); err != nil {
-			slog.Error("unable to close file",
-				slog.Any("error", err),
-			)
+		if !closed {
+			_ = tmp.Close()
+		}
+		if err != nil {
+			if rmErr := os.Remove(tmpPath); rmErr != nil && !errors.Is(rmErr, os.ErrNotExist) {
+				slog.Error("unable to remove temporary file",
+					slog.String("path", tmpPath),
+					slog.Any("error", rmErr),
+				)
+			}
 		}
 	}()
 
 	if b != nil {
-		_, err = file.Write(b)
+		if _, err = writeTemp(tmp, b); err != nil {
+			return err
+		}
+	}
+	if err = tmp.Chmod(perm); err != nil {
+		return err
+	}
+	if err = syncFile(tmp); err != nil {
+		return err
 	}
 
-	return err
+	closed = true
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	if err = renameFile(tmpPath, path); err != nil {
+		return err
+	}
+
+	return syncDir(dir)
+}
+
+func syncDir(path string) error {
+	dir, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err = dir.Close(); err != nil {
+			slog.Error("unable to close directory",
+				slog.Any("error", err),
+			)
+		}
+	}()
+
+	return dir.Sync()
 }
 
 func ReadFile(path string) ([]byte, error) {
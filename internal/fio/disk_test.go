@@ -368,3 +368,135 @@ func TestWriteAndReadPrefixedData(t *testing.T) {
 		}
 	})
 }
+
+func TestWriteFileAtomic(t *testing.T) {
+	t.Run("Keep mode of existing file", func(t *testing.T) {
+		testFilePath := filepath.Join(t.TempDir(), "atomic_mode_test")
+		if err := os.WriteFile(testFilePath, []byte("Old content"), 0600); err != nil {
+			t.Fatalf("Failed to write initial content: %v", err)
+		}
+
+		if err := WriteFileAtomic(testFilePath, []byte("New content")); err != nil {
+			t.Fatalf("Failed to write content: %v", err)
+		}
+
+		info, err := os.Stat(testFilePath)
+		if err != nil {
+			t.Fatalf("Failed to stat file: %v", err)
+		}
+		if info.Mode().Perm() != 0600 {
+			t.Fatalf("Expected mode %v, got %v", os.FileMode(0600), info.Mode().Perm())
+		}
+	})
+
+	interrupted := errors.New("interrupted")
+
+	tests := []struct {
+		name      string
+		interrupt func(t *testing.T)
+	}{
+		{
+			name: "Interrupted halfway through write",
+			interrupt: func(t *testing.T) {
+				original := writeTemp
+				t.Cleanup(func() { writeTemp = original })
+				writeTemp = func(f *os.File, b []byte) (int, error) {
+					n, _ := f.Write(b[:len(b)/2])
+					return n, interrupted
+				}
+			},
+		},
+		{
+			name: "Interrupted during sync",
+			interrupt: func(t *testing.T) {
+				original := syncFile
+				t.Cleanup(func() { syncFile = original })
+				syncFile = func(*os.File) error { return interrupted }
+			},
+		},
+		{
+			name: "Interrupted before rename",
+			interrupt: func(t *testing.T) {
+				original := renameFile
+				t.Cleanup(func() { renameFile = original })
+				renameFile = func(string, string) error { return interrupted }
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			testFilePath := filepath.Join(tempDir, "atomic_test")
+
+			initialContent := []byte("Old content")
+			if err := os.WriteFile(testFilePath, initialContent, 0644); err != nil {
+				t.Fatalf("Failed to write initial content: %v", err)
+			}
+
+			test.interrupt(t)
+
+			err := OverwriteFile(testFilePath, []byte("New content that never fully arrives"))
+			if !errors.Is(err, interrupted) {
+				t.Fatalf("Expected %q error, got: %v", interrupted, err)
+			}
+
+			// The original file is untouched
+			finalContent, err := os.ReadFile(testFilePath)
+			if err != nil {
+				t.Fatalf("Failed to read file: %v", err)
+			}
+			if !bytes.Equal(finalContent, initialContent) {
+				t.Fatalf("Expected content %q, got %q", initialContent, finalContent)
+			}
+
+			// and no temporary files are left behind
+			entries, err := os.ReadDir(tempDir)
+			if err != nil {
+				t.Fatalf("Failed to read dir: %v", err)
+			}
+			if len(entries) != 1 {
+				t.Fatalf("Expected only the original file, found %d entries", len(entries))
+			}
+		})
+	}
+
+	t.Run("Crash leaves a stale temporary file", func(t *testing.T) {
+		tempDir := t.TempDir()
+		testFilePath := filepath.Join(tempDir, "atomic_crash_test")
+
+		initialContent := []byte("Old content")
+		if err := os.WriteFile(testFilePath, initialContent, 0644); err != nil {
+			t.Fatalf("Failed to write initial content: %v", err)
+		}
+
+		// A process that died before renaming leaves its partial temp file.
+		stale := filepath.Join(tempDir, ".atomic_crash_test.tmp-123")
+		if err := os.WriteFile(stale, []byte("New con"), 0644); err != nil {
+			t.Fatalf("Failed to write stale file: %v", err)
+		}
+		if !IsTempFile(filepath.Base(stale)) {
+			t.Fatalf("Expected %s to be a temporary file", filepath.Base(stale))
+		}
+
+		readContent, err := ReadFile(testFilePath)
+		if err != nil {
+			t.Fatalf("Failed to read file: %v", err)
+		}
+		if !bytes.Equal(readContent, initialContent) {
+			t.Fatalf("Expected content %q, got %q", initialContent, readContent)
+		}
+
+		newContent := []byte("New content")
+		if err := OverwriteFile(testFilePath, newContent); err != nil {
+			t.Fatalf("Failed to overwrite content: %v", err)
+		}
+		readContent, err = ReadFile(testFilePath)
+		if err != nil {
+			t.Fatalf("Failed to read file: %v", err)
+		}
+		if !bytes.Equal(readContent, newContent) {
+			t.Fatalf("Expected content %q, got %q", newContent, readContent)
+		}
+	})
+}
@@ -0,0 +1,200 @@
+package fio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+)
+
+// A frame is a checksummed, versioned alternative to the plain length prefix:
+//
+//	magic (4) | version (1) | length (4, little endian) | crc32c (4, little endian) | payload
+const (
+	frameVersion     = 1
+	frameMagicBytes  = 4
+	frameHeaderBytes = frameMagicBytes + 1 + 4 + 4
+	maxFrameBytes    = maxTotalBytes - frameHeaderBytes
+)
+
+var (
+	frameMagic = []byte("TRBL")
+	crcTable   = crc32.MakeTable(crc32.Castagnoli)
+)
+
+var (
+	ErrFrameMagic   = errors.New("frame magic not found")
+	ErrFrameVersion = errors.New("unsupported frame version")
+	ErrChecksum     = errors.New("frame checksum mismatch")
+)
+
+func addFrame(data []byte) ([]byte, error) {
+	dataSize := len(data)
+	if dataSize > maxFrameBytes {
+		return nil, fmt.Errorf("maximum allowed bytes %d exceeded: found %d", maxFrameBytes, dataSize)
+	}
+
+	frame := make([]byte, frameHeaderBytes, frameHeaderBytes+dataSize)
+	copy(frame, frameMagic)
+	frame[frameMagicBytes] = frameVersion
+	binary.LittleEndian.PutUint32(frame[frameMagicBytes+1:], uint32(dataSize))
+	binary.LittleEndian.PutUint32(frame[frameMagicBytes+5:], crc32.Checksum(data, crcTable))
+
+	return append(frame, data...), nil
+}
+
+// frameHeader validates a frame header and returns the payload size and checksum.
+func frameHeader(header []byte) (uint32, uint32, error) {
+	if !bytes.Equal(header[:frameMagicBytes], frameMagic) {
+		return 0, 0, ErrFrameMagic
+	}
+	if version := header[frameMagicBytes]; version != frameVersion {
+		return 0, 0, fmt.Errorf("%w: %d", ErrFrameVersion, version)
+	}
+
+	size := binary.LittleEndian.Uint32(header[frameMagicBytes+1:])
+	if size > maxFrameBytes {
+		return 0, 0, corruptDataError
+	}
+	sum := binary.LittleEndian.Uint32(header[frameMagicBytes+5:])
+
+	return size, sum, nil
+}
+
+// removeFrame returns the offset past the first frame in data and its payload.
+func removeFrame(data []byte) (uint32, []byte, error) {
+	if len(data) < frameHeaderBytes {
+		return 0, nil, ErrTornRecord
+	}
+
+	size, sum, err := frameHeader(data[:frameHeaderBytes])
+	if err != nil {
+		return 0, nil, err
+	}
+
+	offset := uint64(frameHeaderBytes) + uint64(size)
+	if uint64(len(data)) < offset {
+		return 0, nil, ErrTornRecord
+	}
+
+	payload := data[frameHeaderBytes:offset]
+	if crc32.Checksum(payload, crcTable) != sum {
+		return uint32(offset), nil, ErrChecksum
+	}
+
+	return uint32(offset), payload, nil
+}
+
+// LostRange describes a run of bytes that did not hold an intact frame.
+type LostRange struct {
+	Offset int64
+	Length int64
+	Reason error
+}
+
+// ScanReport lists the intact frames found by ScanFrames and the bytes that
+// had to be given up.
+type ScanReport struct {
+	Size    int64
+	Records []Record
+	Lost    []LostRange
+}
+
+func (r *ScanReport) Clean() bool {
+	return len(r.Lost) == 0
+}
+
+func (r *ScanReport) LostBytes() int64 {
+	var n int64
+	for _, l := range r.Lost {
+		n += l.Length
+	}
+	return n
+}
+
+// ScanFrames walks framed data and collects every intact frame. Damaged frames
+// are skipped by searching for the next frame magic, so a single corrupted
+// frame does not hide the frames that follow it.
+func ScanFrames(data []byte) *ScanReport {
+	report := &ScanReport{Size: int64(len(data))}
+
+	var pos int64
+	for pos < report.Size {
+		offset, payload, err := removeFrame(data[pos:])
+		if err == nil {
+			report.Records = append(report.Records, Record{Offset: pos, Data: payload})
+			pos += int64(offset)
+			continue
+		}
+
+		// Resynchronise on the next frame magic after the damaged bytes.
+		next := report.Size
+		if i := bytes.Index(data[pos+1:], frameMagic); i >= 0 {
+			next = pos + 1 + int64(i)
+		}
+		report.Lost = append(report.Lost, LostRange{Offset: pos, Length: next - pos, Reason: err})
+		pos = next
+	}
+
+	return report
+}
+
+type RecoveryMode int
+
+const (
+	// SkipCorrupt keeps every intact frame, also those after a damaged one.
+	SkipCorrupt RecoveryMode = iota
+	// TruncateCorrupt cuts the file off at the first damaged byte.
+	TruncateCorrupt
+)
+
+// RecoverFrames repairs a file of frames in fsys and reports what was lost. The
+// repaired file replaces the damaged one atomically, see OverwriteFile. Files
+// without damage are left untouched.
+func RecoverFrames(fsys FS, path string, mode RecoveryMode) (*ScanReport, error) {
+	content, err := fsys.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(content) > 0 && bytes.Index(content, frameMagic) < 0 {
+		return nil, fmt.Errorf("%s: %w", path, ErrFrameMagic)
+	}
+
+	report := ScanFrames(content)
+	if report.Clean() {
+		return report, nil
+	}
+
+	switch mode {
+	case SkipCorrupt:
+		var b []byte
+		buff := bytes.NewBuffer(b)
+		w := NewFrameWriter(buff)
+		for _, rec := range report.Records {
+			if _, err = w.WriteRecord(rec.Data); err != nil {
+				return nil, err
+			}
+		}
+		err = fsys.OverwriteFile(path, buff.Bytes())
+	case TruncateCorrupt:
+		first := report.Lost[0]
+		kept := report.Records[:0]
+		for _, rec := range report.Records {
+			if rec.Offset < first.Offset {
+				kept = append(kept, rec)
+			}
+		}
+		report.Records = kept
+		report.Lost = []LostRange{{Offset: first.Offset, Length: report.Size - first.Offset, Reason: first.Reason}}
+		err = fsys.OverwriteFile(path, content[:first.Offset])
+	default:
+		return nil, fmt.Errorf("unknown RecoveryMode: %d", mode)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
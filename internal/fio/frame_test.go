@@ -0,0 +1,269 @@
+package fio
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func framedData(t *testing.T, records ...string) ([]byte, []int64) {
+	t.Helper()
+
+	var buff bytes.Buffer
+	w := NewFrameWriter(&buff)
+
+	var offsets []int64
+	for _, r := range records {
+		offset, err := w.WriteRecord([]byte(r))
+		if err != nil {
+			t.Fatalf("Failed to write frame: %v", err)
+		}
+		offsets = append(offsets, offset)
+	}
+	return buff.Bytes(), offsets
+}
+
+func TestAddAndRemoveFrame(t *testing.T) {
+	frame, err := addFrame([]byte("hello"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(frame) != frameHeaderBytes+5 {
+		t.Fatalf("expected size %d, got %d", frameHeaderBytes+5, len(frame))
+	}
+
+	tests := []struct {
+		name          string
+		input         []byte
+		expectedError error
+		expectedData  []byte
+	}{
+		{
+			name:          "Valid frame",
+			input:         frame,
+			expectedError: nil,
+			expectedData:  []byte("hello"),
+		},
+		{
+			name:          "Flipped payload bit",
+			input:         append(bytes.Clone(frame[:len(frame)-1]), frame[len(frame)-1]^0x01),
+			expectedError: ErrChecksum,
+		},
+		{
+			name:          "Wrong magic",
+			input:         append([]byte("XXXX"), frame[frameMagicBytes:]...),
+			expectedError: ErrFrameMagic,
+		},
+		{
+			name:          "Unknown version",
+			input:         append(append(bytes.Clone(frame[:frameMagicBytes]), 9), frame[frameMagicBytes+1:]...),
+			expectedError: ErrFrameVersion,
+		},
+		{
+			name:          "Partial frame",
+			input:         frame[:len(frame)-2],
+			expectedError: ErrTornRecord,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, output, err := removeFrame(test.input)
+
+			if test.expectedError != nil {
+				if !errors.Is(err, test.expectedError) {
+					t.Fatalf("expected error %v, got %v", test.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !bytes.Equal(output, test.expectedData) {
+				t.Fatalf("expected data %q, got %q", test.expectedData, output)
+			}
+		})
+	}
+}
+
+func TestFrameReader(t *testing.T) {
+	data, offsets := framedData(t, "first", "second", "third")
+
+	t.Run("Read frames", func(t *testing.T) {
+		rr, err := DetectRecordReader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("Failed to detect reader: %v", err)
+		}
+
+		var read []string
+		for rec, err := range rr.Records() {
+			if err != nil {
+				t.Fatalf("Failed to read frame: %v", err)
+			}
+			if rec.Offset != offsets[len(read)] {
+				t.Fatalf("Expected offset %d, got %d", offsets[len(read)], rec.Offset)
+			}
+			read = append(read, string(rec.Data))
+		}
+		if len(read) != 3 || read[0] != "first" || read[2] != "third" {
+			t.Fatalf("Unexpected frames %q", read)
+		}
+	})
+
+	t.Run("Detect length prefixed records", func(t *testing.T) {
+		var buff bytes.Buffer
+		if _, err := NewRecordWriter(&buff).WriteRecord([]byte("legacy")); err != nil {
+			t.Fatalf("Failed to write record: %v", err)
+		}
+
+		rr, err := DetectRecordReader(&buff)
+		if err != nil {
+			t.Fatalf("Failed to detect reader: %v", err)
+		}
+		rec, err := rr.Next()
+		if err != nil || string(rec.Data) != "legacy" {
+			t.Fatalf("Expected %q, got %q (%v)", "legacy", rec.Data, err)
+		}
+	})
+
+	t.Run("Checksum mismatch", func(t *testing.T) {
+		damaged := bytes.Clone(data)
+		damaged[offsets[1]+frameHeaderBytes] ^= 0xFF
+
+		rr := NewFrameReader(bytes.NewReader(damaged))
+		if _, err := rr.Next(); err != nil {
+			t.Fatalf("Failed to read first frame: %v", err)
+		}
+		if _, err := rr.Next(); !errors.Is(err, ErrChecksum) {
+			t.Fatalf("Expected %q error, got: %v", ErrChecksum, err)
+		}
+		rec, err := rr.Next()
+		if err != nil || string(rec.Data) != "third" {
+			t.Fatalf("Expected to continue with %q, got %q (%v)", "third", rec.Data, err)
+		}
+	})
+
+	t.Run("Records after a checksum mismatch", func(t *testing.T) {
+		damaged := bytes.Clone(data)
+		damaged[offsets[1]+frameHeaderBytes] ^= 0xFF
+
+		var read []string
+		var errs []error
+		for rec, err := range NewFrameReader(bytes.NewReader(damaged)).Records() {
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			read = append(read, string(rec.Data))
+		}
+		if len(errs) != 1 || !errors.Is(errs[0], ErrChecksum) {
+			t.Fatalf("Expected a single %q error, got: %v", ErrChecksum, errs)
+		}
+		if len(read) != 2 || read[0] != "first" || read[1] != "third" {
+			t.Fatalf("Expected the intact frames, got %q", read)
+		}
+	})
+}
+
+func TestScanFrames(t *testing.T) {
+	data, offsets := framedData(t, "first", "second", "third")
+
+	t.Run("Clean data", func(t *testing.T) {
+		report := ScanFrames(data)
+		if !report.Clean() || len(report.Records) != 3 {
+			t.Fatalf("Expected 3 intact frames, got %d and %d lost", len(report.Records), len(report.Lost))
+		}
+	})
+
+	t.Run("Corrupted frame and torn trailer", func(t *testing.T) {
+		damaged := bytes.Clone(data)
+		damaged[offsets[1]+frameHeaderBytes] ^= 0xFF
+		damaged = append(damaged, data[:frameHeaderBytes+2]...)
+
+		report := ScanFrames(damaged)
+		if len(report.Records) != 2 {
+			t.Fatalf("Expected 2 intact frames, got %d", len(report.Records))
+		}
+		if string(report.Records[0].Data) != "first" || string(report.Records[1].Data) != "third" {
+			t.Fatalf("Unexpected frames %q, %q", report.Records[0].Data, report.Records[1].Data)
+		}
+		if len(report.Lost) != 2 {
+			t.Fatalf("Expected 2 lost ranges, got %d", len(report.Lost))
+		}
+		if report.Lost[0].Offset != offsets[1] || !errors.Is(report.Lost[0].Reason, ErrChecksum) {
+			t.Fatalf("Unexpected first lost range %+v", report.Lost[0])
+		}
+		if report.Lost[1].Offset != int64(len(data)) || !errors.Is(report.Lost[1].Reason, ErrTornRecord) {
+			t.Fatalf("Unexpected second lost range %+v", report.Lost[1])
+		}
+	})
+}
+
+func TestRecoverFrames(t *testing.T) {
+	data, offsets := framedData(t, "first", "second", "third")
+	damaged := bytes.Clone(data)
+	damaged[offsets[1]+frameHeaderBytes] ^= 0xFF
+
+	tests := []struct {
+		name     string
+		mode     RecoveryMode
+		expected []string
+	}{
+		{
+			name:     "Skip corrupt frames",
+			mode:     SkipCorrupt,
+			expected: []string{"first", "third"},
+		},
+		{
+			name:     "Truncate at corrupt frame",
+			mode:     TruncateCorrupt,
+			expected: []string{"first"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			testFilePath := filepath.Join(t.TempDir(), "frames")
+			if err := os.WriteFile(testFilePath, damaged, 0644); err != nil {
+				t.Fatalf("Failed to write frames: %v", err)
+			}
+
+			report, err := RecoverFrames(Disk, testFilePath, test.mode)
+			if err != nil {
+				t.Fatalf("Failed to recover frames: %v", err)
+			}
+			if report.Clean() {
+				t.Fatalf("Expected lost data to be reported")
+			}
+
+			var read []string
+			for rec, err := range ReadRecords(testFilePath) {
+				if err != nil {
+					t.Fatalf("Failed to read recovered file: %v", err)
+				}
+				read = append(read, string(rec.Data))
+			}
+			if len(read) != len(test.expected) {
+				t.Fatalf("Expected frames %q, got %q", test.expected, read)
+			}
+			for i := range read {
+				if read[i] != test.expected[i] {
+					t.Fatalf("Expected frames %q, got %q", test.expected, read)
+				}
+			}
+		})
+	}
+
+	t.Run("Refuse length prefixed file", func(t *testing.T) {
+		testFilePath := filepath.Join(t.TempDir(), "legacy")
+		if err := writePrefixedData(testFilePath, OVERWRITE, []byte("legacy")); err != nil {
+			t.Fatalf("Failed to write data: %v", err)
+		}
+
+		if _, err := RecoverFrames(Disk, testFilePath, SkipCorrupt); !errors.Is(err, ErrFrameMagic) {
+			t.Fatalf("Expected %q error, got: %v", ErrFrameMagic, err)
+		}
+	})
+}
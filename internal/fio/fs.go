@@ -0,0 +1,152 @@
+package fio
+
+import (
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/util"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+)
+
+// FS is where tribble keeps its files. Disk is the file system of the
+// operating system, a Memory keeps the files in a billy.Filesystem so that
+// nothing is written to disk.
+type FS interface {
+	ReadFile(path string) ([]byte, error)
+	// OverwriteFile replaces the contents of the file at path atomically.
+	OverwriteFile(path string, b []byte) error
+	Remove(path string) error
+	// ReadDir returns the entries of the folder at path sorted by name.
+	ReadDir(path string) ([]fs.DirEntry, error)
+	MakeDir(path string) error
+	FileExists(path string) bool
+	AcquireLock(path string, mode LockMode, timeout time.Duration) (*Lock, error)
+}
+
+// Disk is the FS of the operating system.
+var Disk FS = disk{}
+
+type disk struct{}
+
+func (disk) ReadFile(path string) ([]byte, error) {
+	return ReadFile(path)
+}
+
+func (disk) OverwriteFile(path string, b []byte) error {
+	return OverwriteFile(path, b)
+}
+
+func (disk) Remove(path string) error {
+	return os.Remove(path)
+}
+
+func (disk) ReadDir(path string) ([]fs.DirEntry, error) {
+	return os.ReadDir(path)
+}
+
+func (disk) MakeDir(path string) error {
+	return MakeDir(path)
+}
+
+func (disk) FileExists(path string) bool {
+	return FileExists(path)
+}
+
+func (disk) AcquireLock(path string, mode LockMode, timeout time.Duration) (*Lock, error) {
+	return AcquireLock(path, mode, timeout)
+}
+
+// Memory is an FS kept in a billy.Filesystem, such as a memfs. Paths are used
+// as they are, so absolute paths of the disk can be kept in a Memory as well.
+// Its locks only keep out the other users of the same Memory.
+type Memory struct {
+	// mux guards fs and locks; a memfs can not be used concurrently.
+	mux   sync.Mutex
+	fs    billy.Filesystem
+	locks map[string]*sync.RWMutex
+}
+
+func NewMemory(fs billy.Filesystem) *Memory {
+	return &Memory{
+		fs:    fs,
+		locks: make(map[string]*sync.RWMutex),
+	}
+}
+
+// Filesystem returns the filesystem the files are kept in.
+func (m *Memory) Filesystem() billy.Filesystem {
+	return m.fs
+}
+
+func (m *Memory) ReadFile(path string) ([]byte, error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	return util.ReadFile(m.fs, path)
+}
+
+func (m *Memory) OverwriteFile(path string, b []byte) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	return util.WriteFile(m.fs, path, b, 0644)
+}
+
+func (m *Memory) Remove(path string) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	return m.fs.Remove(path)
+}
+
+func (m *Memory) ReadDir(path string) ([]fs.DirEntry, error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	infos, err := m.fs.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]fs.DirEntry, 0, len(infos))
+	for _, info := range infos {
+		entries = append(entries, fs.FileInfoToDirEntry(info))
+	}
+	return entries, nil
+}
+
+func (m *Memory) MakeDir(path string) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	return m.fs.MkdirAll(path, os.ModePerm)
+}
+
+func (m *Memory) FileExists(path string) bool {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	_, err := m.fs.Stat(path)
+	return !os.IsNotExist(err)
+}
+
+func (m *Memory) AcquireLock(path string, mode LockMode, timeout time.Duration) (*Lock, error) {
+	m.mux.Lock()
+	rw, ok := m.locks[path]
+	if !ok {
+		rw = new(sync.RWMutex)
+		m.locks[path] = rw
+	}
+	m.mux.Unlock()
+
+	return acquireLock(path, mode, timeout, func(path string, mode LockMode) (*Lock, error) {
+		switch {
+		case mode == ExclusiveLock && rw.TryLock():
+			return &Lock{path: path, mode: mode, unlock: rw.Unlock}, nil
+		case mode == SharedLock && rw.TryRLock():
+			return &Lock{path: path, mode: mode, unlock: rw.RUnlock}, nil
+		default:
+			return nil, errLocked
+		}
+	})
+}
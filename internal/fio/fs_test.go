@@ -0,0 +1,86 @@
+package fio
+
+import (
+	"errors"
+	"github.com/go-git/go-billy/v5/memfs"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestMemory(t *testing.T) {
+	m := NewMemory(memfs.New())
+	dir := "/project/.tribble"
+	path := dir + "/tasks.md"
+
+	t.Run("Missing files", func(t *testing.T) {
+		if m.FileExists(path) {
+			t.Fatalf("Expected %s to be missing", path)
+		}
+		if _, err := m.ReadFile(path); !errors.Is(err, os.ErrNotExist) {
+			t.Fatalf("Expected error: %v, got: %v", os.ErrNotExist, err)
+		}
+		if _, err := m.ReadDir(dir); !errors.Is(err, os.ErrNotExist) {
+			t.Fatalf("Expected error: %v, got: %v", os.ErrNotExist, err)
+		}
+		if err := m.Remove(path); !errors.Is(err, os.ErrNotExist) {
+			t.Fatalf("Expected error: %v, got: %v", os.ErrNotExist, err)
+		}
+	})
+
+	t.Run("Write and read", func(t *testing.T) {
+		if err := m.OverwriteFile(path, []byte("first")); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+		if err := m.OverwriteFile(path, []byte("second")); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+
+		content, err := m.ReadFile(path)
+		if err != nil {
+			t.Fatalf("Failed to read file: %v", err)
+		}
+		if string(content) != "second" {
+			t.Fatalf("Expected %q, got %q", "second", content)
+		}
+		entries, err := m.ReadDir(dir)
+		if err != nil {
+			t.Fatalf("Failed to read folder: %v", err)
+		}
+		if len(entries) != 1 || entries[0].Name() != "tasks.md" || entries[0].IsDir() {
+			t.Fatalf("Expected tasks.md, got %v", entries)
+		}
+	})
+
+	t.Run("Locks", func(t *testing.T) {
+		lockPath := dir + "/tasks.lock"
+
+		held, err := m.AcquireLock(lockPath, SharedLock, 0)
+		if err != nil {
+			t.Fatalf("Failed to acquire lock: %v", err)
+		}
+		shared, err := m.AcquireLock(lockPath, SharedLock, 0)
+		if err != nil {
+			t.Fatalf("Failed to acquire shared lock: %v", err)
+		}
+		if _, err = m.AcquireLock(lockPath, ExclusiveLock, 20*time.Millisecond); !errors.Is(err, ErrLockTimeout) {
+			t.Fatalf("Expected error: %v, got: %v", ErrLockTimeout, err)
+		}
+
+		for _, l := range []*Lock{held, shared} {
+			if err = l.Release(); err != nil {
+				t.Fatalf("Failed to release lock: %v", err)
+			}
+		}
+		exclusive, err := m.AcquireLock(lockPath, ExclusiveLock, 0)
+		if err != nil {
+			t.Fatalf("Failed to acquire released lock: %v", err)
+		}
+		if err = exclusive.Release(); err != nil {
+			t.Fatalf("Failed to release lock: %v", err)
+		}
+		if m.FileExists(lockPath) {
+			t.Fatalf("Expected no lock file")
+		}
+	})
+}
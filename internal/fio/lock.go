@@ -0,0 +1,153 @@
+package fio
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+type LockMode int
+
+const (
+	SharedLock LockMode = iota
+	ExclusiveLock
+)
+
+func (m LockMode) String() string {
+	switch m {
+	case SharedLock:
+		return "shared"
+	case ExclusiveLock:
+		return "exclusive"
+	default:
+		return fmt.Sprintf("LockMode(%d)", int(m))
+	}
+}
+
+var ErrLockTimeout = errors.New("timed out waiting for lock")
+
+// errLocked is returned by tryLock when another holder keeps us from locking.
+var errLocked = errors.New("locked")
+
+const (
+	minLockDelay = 5 * time.Millisecond
+	maxLockDelay = 100 * time.Millisecond
+	// staleLockAge is the age after which a lock file whose owner can not be
+	// verified is considered abandoned.
+	staleLockAge = 10 * time.Minute
+)
+
+// Lock is an advisory lock on a lock file, shared between processes. Locks of
+// a Memory have no file and are only shared by the users of that Memory.
+type Lock struct {
+	path   string
+	mode   LockMode
+	file   *os.File
+	unlock func()
+}
+
+// lockOwner is written to the lock file by exclusive holders so that waiting
+// processes can report who holds the lock, and detect abandoned lock files.
+type lockOwner struct {
+	PID      int       `json:"pid"`
+	Host     string    `json:"host"`
+	Acquired time.Time `json:"acquired"`
+}
+
+// AcquireLock locks the lock file at path, creating it if needed. It retries
+// until the lock is acquired or the timeout expires; a negative timeout waits
+// indefinitely and a zero timeout tries exactly once.
+func AcquireLock(path string, mode LockMode, timeout time.Duration) (*Lock, error) {
+	l, err := acquireLock(path, mode, timeout, tryLock)
+	if errors.Is(err, ErrLockTimeout) {
+		info, statErr := os.Stat(path)
+		if owner, ok := readLockOwner(path); ok && statErr == nil && !owner.stale(info.ModTime()) {
+			return nil, fmt.Errorf("%w %s: held by pid %d on %s since %s", ErrLockTimeout, path, owner.PID, owner.Host, owner.Acquired.Format(time.RFC3339))
+		}
+	}
+	return l, err
+}
+
+// acquireLock calls try until it takes the lock or the timeout expires.
+func acquireLock(path string, mode LockMode, timeout time.Duration, try func(string, LockMode) (*Lock, error)) (*Lock, error) {
+	if mode != SharedLock && mode != ExclusiveLock {
+		return nil, fmt.Errorf("unknown LockMode: %d", mode)
+	}
+
+	deadline := time.Now().Add(timeout)
+	delay := minLockDelay
+
+	for {
+		l, err := try(path, mode)
+		if err == nil {
+			return l, nil
+		}
+		if !errors.Is(err, errLocked) {
+			return nil, err
+		}
+
+		if timeout >= 0 && !time.Now().Before(deadline) {
+			return nil, fmt.Errorf("%w %s", ErrLockTimeout, path)
+		}
+
+		time.Sleep(delay)
+		delay = min(delay*2, maxLockDelay)
+	}
+}
+
+func (l *Lock) Path() string {
+	return l.path
+}
+
+func (l *Lock) Mode() LockMode {
+	return l.mode
+}
+
+func newLockOwner() lockOwner {
+	host, _ := os.Hostname()
+	return lockOwner{
+		PID:      os.Getpid(),
+		Host:     host,
+		Acquired: time.Now().UTC(),
+	}
+}
+
+func writeLockOwner(file *os.File) error {
+	b, err := json.Marshal(newLockOwner())
+	if err != nil {
+		return err
+	}
+	if err = file.Truncate(0); err != nil {
+		return err
+	}
+	_, err = file.WriteAt(b, 0)
+	return err
+}
+
+func readLockOwner(path string) (lockOwner, bool) {
+	var owner lockOwner
+
+	b, err := os.ReadFile(path)
+	if err != nil || len(b) == 0 {
+		return owner, false
+	}
+	if err = json.Unmarshal(b, &owner); err != nil {
+		return owner, false
+	}
+	return owner, owner.PID > 0
+}
+
+// stale reports whether the owner of a lock file has gone away without
+// releasing it. A lock file whose owner can not be checked is stale once it was
+// last modified staleLockAge ago.
+func (o lockOwner) stale(modified time.Time) bool {
+	host, _ := os.Hostname()
+	if o.Host == host && o.PID > 0 {
+		if alive, ok := processAlive(o.PID); ok {
+			return !alive
+		}
+	}
+	return time.Since(modified) > staleLockAge
+}
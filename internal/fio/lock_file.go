@@ -0,0 +1,75 @@
+//go:build !(linux || darwin || dragonfly || freebsd || netbsd || openbsd)
+
+package fio
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+)
+
+// tryLock creates the lock file exclusively. Platforms without flock have no
+// shared locks, so shared locks are taken exclusively as well. A lock file left
+// behind by a process that no longer exists is removed.
+func tryLock(path string, mode LockMode) (*Lock, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_RDWR, 0644)
+	if errors.Is(err, os.ErrExist) {
+		if !removeStaleLock(path) {
+			return nil, errLocked
+		}
+		file, err = os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_RDWR, 0644)
+		if errors.Is(err, os.ErrExist) {
+			return nil, errLocked
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err = writeLockOwner(file); err != nil {
+		_ = file.Close()
+		_ = os.Remove(path)
+		return nil, err
+	}
+
+	return &Lock{path: path, mode: mode, file: file}, nil
+}
+
+func removeStaleLock(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil {
+		return errors.Is(err, os.ErrNotExist)
+	}
+
+	owner, ok := readLockOwner(path)
+	if ok && !owner.stale(info.ModTime()) {
+		return false
+	}
+	if !ok && !(lockOwner{}).stale(info.ModTime()) {
+		return false
+	}
+
+	slog.Warn("removing stale lock",
+		slog.String("path", path),
+		slog.Int("pid", owner.PID),
+	)
+	err = os.Remove(path)
+	return err == nil || errors.Is(err, os.ErrNotExist)
+}
+
+// Release unlocks the lock by removing the lock file.
+func (l *Lock) Release() error {
+	if l.unlock != nil {
+		l.unlock()
+		l.unlock = nil
+		return nil
+	}
+	if l.file == nil {
+		return nil
+	}
+
+	closeErr := l.file.Close()
+	l.file = nil
+
+	return errors.Join(closeErr, os.Remove(l.path))
+}
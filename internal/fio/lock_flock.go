@@ -0,0 +1,76 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+
+package fio
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"syscall"
+)
+
+// tryLock takes a flock on the lock file. The kernel drops the lock when its
+// holder exits, so a lock file left behind by a crashed process never blocks.
+func tryLock(path string, mode LockMode) (*Lock, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	how := syscall.LOCK_SH
+	if mode == ExclusiveLock {
+		how = syscall.LOCK_EX
+	}
+
+	err = flock(file, how|syscall.LOCK_NB)
+	if err != nil {
+		_ = file.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, errLocked
+		}
+		return nil, err
+	}
+
+	if mode == ExclusiveLock {
+		if err = writeLockOwner(file); err != nil {
+			slog.Warn("unable to record lock owner",
+				slog.String("path", path),
+				slog.Any("error", err),
+			)
+		}
+	}
+
+	return &Lock{path: path, mode: mode, file: file}, nil
+}
+
+// Release unlocks the lock. The lock file itself is kept, removing it could
+// allow two processes to lock different files under the same name.
+func (l *Lock) Release() error {
+	if l.unlock != nil {
+		l.unlock()
+		l.unlock = nil
+		return nil
+	}
+	if l.file == nil {
+		return nil
+	}
+
+	if l.mode == ExclusiveLock {
+		_ = l.file.Truncate(0)
+	}
+
+	err := flock(l.file, syscall.LOCK_UN)
+	closeErr := l.file.Close()
+	l.file = nil
+
+	return errors.Join(err, closeErr)
+}
+
+func flock(file *os.File, how int) error {
+	for {
+		err := syscall.Flock(int(file.Fd()), how)
+		if !errors.Is(err, syscall.EINTR) {
+			return err
+		}
+	}
+}
@@ -0,0 +1,24 @@
+//go:build !unix
+
+package fio
+
+import (
+	"os"
+	"runtime"
+)
+
+// processAlive reports whether a process with the given pid exists. Only
+// Windows can tell: FindProcess opens the process there and fails once it has
+// exited. Elsewhere FindProcess always succeeds, so ok is false.
+func processAlive(pid int) (alive, ok bool) {
+	if runtime.GOOS != "windows" {
+		return false, false
+	}
+
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false, true
+	}
+	_ = p.Release()
+	return true, true
+}
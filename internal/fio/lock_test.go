@@ -0,0 +1,131 @@
+package fio
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAcquireLock(t *testing.T) {
+	tests := []struct {
+		name        string
+		held        LockMode
+		requested   LockMode
+		expectedErr error
+	}{
+		{
+			name:        "Shared locks do not conflict",
+			held:        SharedLock,
+			requested:   SharedLock,
+			expectedErr: nil,
+		},
+		{
+			name:        "Shared lock blocks exclusive lock",
+			held:        SharedLock,
+			requested:   ExclusiveLock,
+			expectedErr: ErrLockTimeout,
+		},
+		{
+			name:        "Exclusive lock blocks shared lock",
+			held:        ExclusiveLock,
+			requested:   SharedLock,
+			expectedErr: ErrLockTimeout,
+		},
+		{
+			name:        "Exclusive lock blocks exclusive lock",
+			held:        ExclusiveLock,
+			requested:   ExclusiveLock,
+			expectedErr: ErrLockTimeout,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			lockPath := filepath.Join(t.TempDir(), "test.lock")
+
+			held, err := AcquireLock(lockPath, test.held, 0)
+			if err != nil {
+				t.Fatalf("Failed to acquire lock: %v", err)
+			}
+			defer func() { _ = held.Release() }()
+
+			requested, err := AcquireLock(lockPath, test.requested, 20*time.Millisecond)
+			if !errors.Is(err, test.expectedErr) {
+				t.Fatalf("Expected error: %v, got: %v", test.expectedErr, err)
+			}
+			if requested != nil {
+				_ = requested.Release()
+			}
+		})
+	}
+}
+
+func TestLockRelease(t *testing.T) {
+	lockPath := filepath.Join(t.TempDir(), "test.lock")
+
+	held, err := AcquireLock(lockPath, ExclusiveLock, 0)
+	if err != nil {
+		t.Fatalf("Failed to acquire lock: %v", err)
+	}
+
+	t.Run("Timeout reports the holder", func(t *testing.T) {
+		_, err := AcquireLock(lockPath, ExclusiveLock, 0)
+		if !errors.Is(err, ErrLockTimeout) {
+			t.Fatalf("Expected %q error, got: %v", ErrLockTimeout, err)
+		}
+		if !strings.Contains(err.Error(), "pid "+strconv.Itoa(os.Getpid())) {
+			t.Fatalf("Expected error to name the holder, got: %v", err)
+		}
+	})
+
+	t.Run("Waiting lock is acquired after release", func(t *testing.T) {
+		released := make(chan error)
+		go func() {
+			time.Sleep(20 * time.Millisecond)
+			released <- held.Release()
+		}()
+
+		l, err := AcquireLock(lockPath, ExclusiveLock, 5*time.Second)
+		if err != nil {
+			t.Fatalf("Failed to acquire lock: %v", err)
+		}
+		if err = l.Release(); err != nil {
+			t.Fatalf("Failed to release lock: %v", err)
+		}
+		if err = <-released; err != nil {
+			t.Fatalf("Failed to release lock: %v", err)
+		}
+	})
+
+	t.Run("Release twice", func(t *testing.T) {
+		if err := held.Release(); err != nil {
+			t.Fatalf("Expected second release to be a no-op, got: %v", err)
+		}
+	})
+}
+
+func TestStaleLockOwner(t *testing.T) {
+	alive := newLockOwner()
+	if alive.stale(time.Now()) {
+		t.Fatalf("Expected lock owned by this process not to be stale")
+	}
+
+	gone := newLockOwner()
+	gone.PID = 1 << 30 // beyond any pid the kernel hands out
+	if !gone.stale(time.Now()) {
+		t.Fatalf("Expected lock owned by a missing process to be stale")
+	}
+
+	remote := newLockOwner()
+	remote.Host = "elsewhere"
+	if remote.stale(time.Now()) {
+		t.Fatalf("Expected recent lock from another host not to be stale")
+	}
+	if !remote.stale(time.Now().Add(-2 * staleLockAge)) {
+		t.Fatalf("Expected old lock from another host to be stale")
+	}
+}
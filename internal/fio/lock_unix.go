@@ -0,0 +1,15 @@
+//go:build unix
+
+package fio
+
+import (
+	"errors"
+	"syscall"
+)
+
+// processAlive reports whether a process with the given pid exists, by sending
+// it signal 0.
+func processAlive(pid int) (alive, ok bool) {
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM), true
+}
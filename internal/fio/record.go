@@ -0,0 +1,221 @@
+package fio
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"iter"
+	"log/slog"
+	"os"
+)
+
+// ErrTornRecord is returned when a stream ends in the middle of a record,
+// typically because a write was interrupted.
+var ErrTornRecord = errors.New("torn record")
+
+// Record is the payload of a single record and the byte offset of its header.
+type Record struct {
+	Offset int64
+	Data   []byte
+}
+
+// RecordReader reads consecutive records from a stream. Records are either
+// plain length prefixed, as written by repeated APPEND calls to
+// writePrefixedData, or checksummed frames.
+type RecordReader struct {
+	r      *bufio.Reader
+	offset int64
+	framed bool
+}
+
+// NewRecordReader returns a reader for plain length prefixed records.
+func NewRecordReader(r io.Reader) *RecordReader {
+	return &RecordReader{r: bufio.NewReader(r)}
+}
+
+// NewFrameReader returns a reader for checksummed frames.
+func NewFrameReader(r io.Reader) *RecordReader {
+	return &RecordReader{r: bufio.NewReader(r), framed: true}
+}
+
+// DetectRecordReader returns a frame reader if the stream starts with a frame
+// and a plain length prefix reader otherwise.
+func DetectRecordReader(r io.Reader) (*RecordReader, error) {
+	rr := NewRecordReader(r)
+
+	magic, err := rr.r.Peek(frameMagicBytes)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	rr.framed = bytes.Equal(magic, frameMagic)
+
+	return rr, nil
+}
+
+// Offset returns the byte offset of the next record.
+func (rr *RecordReader) Offset() int64 {
+	return rr.offset
+}
+
+// Next returns the next record. It returns io.EOF when the stream ends cleanly
+// after a record, and an error wrapping ErrTornRecord when the stream ends
+// inside a record. In both cases the returned record holds the offset at which
+// the valid data ends.
+//
+// A frame whose checksum does not match is reported with an error wrapping
+// ErrChecksum; the reader then continues after that frame.
+func (rr *RecordReader) Next() (Record, error) {
+	rec := Record{Offset: rr.offset}
+
+	headerSize := prefixBytes
+	if rr.framed {
+		headerSize = frameHeaderBytes
+	}
+
+	header := make([]byte, headerSize)
+	n, err := io.ReadFull(rr.r, header)
+	switch {
+	case errors.Is(err, io.EOF):
+		return rec, io.EOF
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return rec, fmt.Errorf("%w at offset %d: %d of %d header bytes", ErrTornRecord, rec.Offset, n, headerSize)
+	case err != nil:
+		return rec, err
+	}
+
+	var size int64
+	var sum uint32
+	if rr.framed {
+		s, c, err := frameHeader(header)
+		if err != nil {
+			return rec, fmt.Errorf("%w at offset %d", err, rec.Offset)
+		}
+		size, sum = int64(s), c
+	} else {
+		size = int64(binary.LittleEndian.Uint32(header))
+		if size > maxDataBytes {
+			return rec, fmt.Errorf("%w at offset %d", corruptDataError, rec.Offset)
+		}
+	}
+
+	// Copy instead of allocating size bytes up front, a corrupt prefix must not
+	// make us allocate gigabytes for data that is not there.
+	var b []byte
+	buff := bytes.NewBuffer(b)
+	copied, err := io.CopyN(buff, rr.r, size)
+	if errors.Is(err, io.EOF) {
+		return rec, fmt.Errorf("%w at offset %d: %d of %d data bytes", ErrTornRecord, rec.Offset, copied, size)
+	}
+	if err != nil {
+		return rec, err
+	}
+
+	rr.offset += int64(headerSize) + size
+	if rr.framed && crc32.Checksum(buff.Bytes(), crcTable) != sum {
+		return rec, fmt.Errorf("%w at offset %d", ErrChecksum, rec.Offset)
+	}
+
+	rec.Data = buff.Bytes()
+	return rec, nil
+}
+
+// Records returns an iterator over the remaining records. Iteration stops
+// silently at a clean end of the stream. A frame whose checksum does not match
+// is yielded with an error wrapping ErrChecksum and iteration continues after
+// it; any other error is yielded once, after which iteration stops.
+func (rr *RecordReader) Records() iter.Seq2[Record, error] {
+	return func(yield func(Record, error) bool) {
+		for {
+			rec, err := rr.Next()
+			if errors.Is(err, io.EOF) {
+				return
+			}
+			if !yield(rec, err) || err != nil && !errors.Is(err, ErrChecksum) {
+				return
+			}
+		}
+	}
+}
+
+// ReadRecords iterates over the records in the file at path without loading
+// the whole file into memory. Both plain length prefixed records and frames are
+// recognised. The file is closed when iteration ends.
+func ReadRecords(path string) iter.Seq2[Record, error] {
+	return func(yield func(Record, error) bool) {
+		file, err := os.Open(path)
+		if err != nil {
+			yield(Record{}, err)
+			return
+		}
+		defer func() {
+			if err = file.Close(); err != nil {
+				slog.Error("unable to close file",
+					slog.Any("error", err),
+				)
+			}
+		}()
+
+		rr, err := DetectRecordReader(file)
+		if err != nil {
+			yield(Record{}, err)
+			return
+		}
+
+		for rec, err := range rr.Records() {
+			if !yield(rec, err) {
+				return
+			}
+		}
+	}
+}
+
+// RecordWriter writes records to a stream.
+type RecordWriter struct {
+	w      io.Writer
+	offset int64
+	framed bool
+}
+
+// NewRecordWriter returns a writer for plain length prefixed records.
+func NewRecordWriter(w io.Writer) *RecordWriter {
+	return &RecordWriter{w: w}
+}
+
+// NewFrameWriter returns a writer for checksummed frames.
+func NewFrameWriter(w io.Writer) *RecordWriter {
+	return &RecordWriter{w: w, framed: true}
+}
+
+// Offset returns the byte offset at which the next record will be written.
+func (rw *RecordWriter) Offset() int64 {
+	return rw.offset
+}
+
+// WriteRecord writes data as a single record and returns the offset of the
+// record.
+func (rw *RecordWriter) WriteRecord(data []byte) (int64, error) {
+	var prefixed []byte
+	var err error
+
+	if rw.framed {
+		prefixed, err = addFrame(data)
+	} else {
+		prefixed, err = addLengthPrefix(data)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	offset := rw.offset
+	n, err := rw.w.Write(prefixed)
+	rw.offset += int64(n)
+	if err != nil {
+		return 0, err
+	}
+
+	return offset, nil
+}
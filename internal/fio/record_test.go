@@ -0,0 +1,155 @@
+package fio
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"path/filepath"
+	"testing"
+)
+
+func TestRecordWriterAndReader(t *testing.T) {
+	records := [][]byte{[]byte("first"), {}, []byte("third record")}
+
+	var buff bytes.Buffer
+	rw := NewRecordWriter(&buff)
+
+	var offsets []int64
+	for _, data := range records {
+		offset, err := rw.WriteRecord(data)
+		if err != nil {
+			t.Fatalf("Failed to write record: %v", err)
+		}
+		offsets = append(offsets, offset)
+	}
+
+	expectedOffsets := []int64{0, prefixBytes + 5, 2*prefixBytes + 5}
+	for i := range expectedOffsets {
+		if offsets[i] != expectedOffsets[i] {
+			t.Fatalf("Expected offset %d, got %d", expectedOffsets[i], offsets[i])
+		}
+	}
+	if rw.Offset() != int64(buff.Len()) {
+		t.Fatalf("Expected writer offset %d, got %d", buff.Len(), rw.Offset())
+	}
+
+	i := 0
+	for rec, err := range NewRecordReader(&buff).Records() {
+		if err != nil {
+			t.Fatalf("Failed to read record: %v", err)
+		}
+		if rec.Offset != offsets[i] {
+			t.Fatalf("Expected offset %d, got %d", offsets[i], rec.Offset)
+		}
+		if !bytes.Equal(rec.Data, records[i]) {
+			t.Fatalf("Expected record %q, got %q", records[i], rec.Data)
+		}
+		i++
+	}
+	if i != len(records) {
+		t.Fatalf("Expected %d records, got %d", len(records), i)
+	}
+}
+
+func TestRecordReaderTornRecord(t *testing.T) {
+	var complete bytes.Buffer
+	if _, err := NewRecordWriter(&complete).WriteRecord([]byte("complete")); err != nil {
+		t.Fatalf("Failed to write record: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		trailer []byte
+	}{
+		{
+			name:    "Partial prefix",
+			trailer: []byte{0x05, 0x00},
+		},
+		{
+			name:    "Partial data",
+			trailer: []byte{0x05, 0x00, 0x00, 0x00, 'h', 'e'},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stream := append(bytes.Clone(complete.Bytes()), test.trailer...)
+			rr := NewRecordReader(bytes.NewReader(stream))
+
+			rec, err := rr.Next()
+			if err != nil {
+				t.Fatalf("Failed to read first record: %v", err)
+			}
+			if string(rec.Data) != "complete" {
+				t.Fatalf("Expected %q, got %q", "complete", rec.Data)
+			}
+
+			rec, err = rr.Next()
+			if !errors.Is(err, ErrTornRecord) {
+				t.Fatalf("Expected %q error, got: %v", ErrTornRecord, err)
+			}
+			if rec.Offset != int64(complete.Len()) {
+				t.Fatalf("Expected torn record at offset %d, got %d", complete.Len(), rec.Offset)
+			}
+		})
+	}
+
+	t.Run("Clean end", func(t *testing.T) {
+		rr := NewRecordReader(bytes.NewReader(complete.Bytes()))
+		if _, err := rr.Next(); err != nil {
+			t.Fatalf("Failed to read first record: %v", err)
+		}
+		if _, err := rr.Next(); !errors.Is(err, io.EOF) {
+			t.Fatalf("Expected %q error, got: %v", io.EOF, err)
+		}
+	})
+}
+
+func TestReadRecords(t *testing.T) {
+	testFilePath := filepath.Join(t.TempDir(), "records")
+
+	pieces := []string{"first piece", "second piece", "third piece"}
+	for _, piece := range pieces {
+		if err := writePrefixedData(testFilePath, APPEND, []byte(piece)); err != nil {
+			t.Fatalf("Failed to append data: %v", err)
+		}
+	}
+
+	t.Run("All records", func(t *testing.T) {
+		var read []string
+		for rec, err := range ReadRecords(testFilePath) {
+			if err != nil {
+				t.Fatalf("Failed to read record: %v", err)
+			}
+			read = append(read, string(rec.Data))
+		}
+		if len(read) != len(pieces) {
+			t.Fatalf("Expected %d records, got %d", len(pieces), len(read))
+		}
+		for i := range pieces {
+			if read[i] != pieces[i] {
+				t.Fatalf("Expected record %q, got %q", pieces[i], read[i])
+			}
+		}
+	})
+
+	t.Run("Stop early", func(t *testing.T) {
+		count := 0
+		for range ReadRecords(testFilePath) {
+			count++
+			break
+		}
+		if count != 1 {
+			t.Fatalf("Expected a single record, got %d", count)
+		}
+	})
+
+	t.Run("Missing file", func(t *testing.T) {
+		var err error
+		for _, err = range ReadRecords(filepath.Join(t.TempDir(), "missing")) {
+		}
+		if err == nil {
+			t.Fatalf("Expected error, got none")
+		}
+	})
+}
@@ -0,0 +1,68 @@
+package gui
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+)
+
+const (
+	csrfCookie = "tribble_csrf"
+	// csrfField is the form field that carries the token of a form.
+	csrfField = "csrf_token"
+	// csrfHeader carries the token of requests made by scripts.
+	csrfHeader = "X-CSRF-Token"
+	csrfBytes  = 32
+)
+
+// csrfToken returns the token forms must send back, which is kept in a
+// cookie. A new token is made when the request has none.
+func csrfToken(w http.ResponseWriter, r *http.Request) (string, error) {
+	if c, err := r.Cookie(csrfCookie); err == nil && len(c.Value) == 2*csrfBytes {
+		return c.Value, nil
+	}
+
+	b := make([]byte, csrfBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(b)
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     csrfCookie,
+		Value:    token,
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+	// Later calls for the same request have to see the new token.
+	r.AddCookie(&http.Cookie{Name: csrfCookie, Value: token})
+	return token, nil
+}
+
+// validCSRF reports whether the request sent the token of its cookie, in the
+// form or in csrfHeader. Another site can make a browser send the cookie, but
+// cannot read it to fill in the form.
+func validCSRF(r *http.Request) bool {
+	c, err := r.Cookie(csrfCookie)
+	if err != nil || c.Value == "" {
+		return false
+	}
+	token := r.Header.Get(csrfHeader)
+	if token == "" {
+		token = r.PostFormValue(csrfField)
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(c.Value)) == 1
+}
+
+// protect rejects requests that do not carry a valid CSRF token.
+func protect(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !validCSRF(r) {
+			http.Error(w, "invalid CSRF token", http.StatusForbidden)
+			return
+		}
+		h(w, r)
+	}
+}
@@ -30,7 +30,12 @@ func (fs NoDirFileSystem) Open(path string) (http.File, error) {
 	}
 
 	s, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
 	if s.IsDir() {
+		_ = f.Close()
 		return nil, syscall.ENOENT
 	}
 
@@ -0,0 +1,116 @@
+package gui
+
+import (
+	"errors"
+	"github.com/tedla-brandsema/tribble/task"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// errInvalidForm stops saving a task when its form has errors.
+var errInvalidForm = errors.New("invalid task form")
+
+// taskForm is the data of the task form. The values are kept as entered, so
+// a form with errors is shown again the way it was sent.
+type taskForm struct {
+	// Ref refers to the task being edited; it is empty for a new task.
+	Ref  string
+	CSRF string
+	// Modified is the modification time of the task when the form was
+	// loaded, to notice changes made to it by others before the form is sent.
+	Modified string
+
+	Title       string
+	Description string
+	Status      string
+	Priority    string
+	Tags        string
+	Due         string
+
+	// Errors holds a message per field that could not be set.
+	Errors map[string]string
+
+	Statuses   []task.Status
+	Priorities []task.Priority
+}
+
+func newTaskForm(ref string, t *task.Task) taskForm {
+	f := taskForm{
+		Ref:        ref,
+		Status:     string(task.StatusTodo),
+		Errors:     make(map[string]string),
+		Statuses:   task.Statuses,
+		Priorities: task.Priorities,
+	}
+	if t != nil {
+		f.Title = t.Title
+		f.Description = t.Description
+		f.Status = string(t.Status)
+		f.Priority = string(t.Priority)
+		f.Tags = strings.Join(t.Tags, ", ")
+		f.Due = task.FormatDue(t.Due)
+		f.Modified = t.Modified.Format(time.RFC3339)
+	}
+	return f
+}
+
+// parseTaskForm returns the form sent with r.
+func parseTaskForm(ref string, r *http.Request) taskForm {
+	f := newTaskForm(ref, nil)
+	f.Title = r.PostFormValue("title")
+	f.Description = r.PostFormValue("description")
+	f.Status = r.PostFormValue("status")
+	f.Priority = r.PostFormValue("priority")
+	f.Tags = r.PostFormValue("tags")
+	f.Due = r.PostFormValue("due")
+	f.Modified = r.PostFormValue("modified")
+	return f
+}
+
+// Action returns the URL the form is sent to.
+func (f taskForm) Action() string {
+	if f.Ref == "" {
+		return "/tasks"
+	}
+	return "/tasks/" + f.Ref
+}
+
+func (f taskForm) Valid() bool {
+	return len(f.Errors) == 0
+}
+
+// apply sets every field but the title and description of t to the values of
+// the form, and records an error for each value that is not valid.
+func (f *taskForm) apply(t *task.Task) {
+	f.check("Status", t.SetStatus(task.Status(f.Status)))
+	f.check("Priority", t.SetPriority(task.Priority(f.Priority)))
+	f.check("Tags", t.SetTags(task.ParseTags(f.Tags)))
+
+	due, err := task.ParseDue(f.Due)
+	if f.check("Due", err) {
+		t.SetDue(due)
+	}
+}
+
+// current reports whether t is unchanged since the form was loaded. If it is
+// not, an error is recorded and the form takes the modification time of t, so
+// sending the form again overwrites the changes.
+func (f *taskForm) current(t *task.Task) bool {
+	modified := t.Modified.Format(time.RFC3339)
+	if f.Modified == "" || f.Modified == modified {
+		return true
+	}
+	f.Errors["Task"] = "The task was changed since this form was loaded. Save again to overwrite those changes."
+	f.Modified = modified
+	return false
+}
+
+// check records err as the error of field and reports whether there was none.
+func (f *taskForm) check(field string, err error) bool {
+	if err == nil {
+		return true
+	}
+	f.Errors[field] = err.Error()
+	return false
+}
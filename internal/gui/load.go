@@ -2,6 +2,7 @@ package gui
 
 import (
 	"embed"
+	"fmt"
 	"html/template"
 	"net/http"
 	"path/filepath"
@@ -41,9 +42,19 @@ func Base() *template.Template {
 }
 
 func ViewFS(fileName string) (*template.Template, error) {
+	return PartialFS(fileName, "view.html")
+}
+
+// PartialFS returns the template name defined in a file of the view folder,
+// to be merged along with a view that uses it.
+func PartialFS(fileName, name string) (*template.Template, error) {
 	t, err := template.ParseFS(viewFS, filepath.Join("tmpl", "view", fileName))
 	if err != nil {
 		return nil, err
 	}
-	return t.Lookup("view.html"), nil
+	partial := t.Lookup(name)
+	if partial == nil {
+		return nil, fmt.Errorf("%s does not define %s", fileName, name)
+	}
+	return partial, nil
 }
@@ -0,0 +1,498 @@
+package gui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/tedla-brandsema/tribble/backlog"
+	"github.com/tedla-brandsema/tribble/internal/search"
+	"github.com/tedla-brandsema/tribble/project"
+	"github.com/tedla-brandsema/tribble/task"
+	"html/template"
+	"log/slog"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// ShutdownTimeout is how long requests in flight may take to finish once
+	// the server is stopped.
+	ShutdownTimeout = 5 * time.Second
+
+	// nextTasks is the number of open tasks listed on the home page.
+	nextTasks = 5
+)
+
+var views = []string{
+	"404.tmpl",
+	"home.tmpl",
+	"search.tmpl",
+	"task.tmpl",
+	"task-form.tmpl",
+	"task-list.tmpl",
+}
+
+// Server serves the web interface of a project.
+type Server struct {
+	project *project.Project
+	pages   map[string]*template.Template
+	mux     *http.ServeMux
+}
+
+// NewServer returns a Server for p, with every view merged into the base
+// template.
+func NewServer(p *project.Project) (*Server, error) {
+	s := &Server{
+		project: p,
+		pages:   make(map[string]*template.Template, len(views)),
+		mux:     http.NewServeMux(),
+	}
+
+	for _, name := range views {
+		view, err := ViewFS(name)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load view %s: %w", name, err)
+		}
+		s.pages[name] = Merge(Base(), view).Lookup("index.html")
+	}
+
+	board, err := ViewFS("board.tmpl")
+	if err != nil {
+		return nil, fmt.Errorf("unable to load view board.tmpl: %w", err)
+	}
+	card, err := PartialFS("card.tmpl", "card.html")
+	if err != nil {
+		return nil, fmt.Errorf("unable to load card.tmpl: %w", err)
+	}
+	s.pages["board.tmpl"] = Merge(Base(), board, card).Lookup("index.html")
+
+	s.mux.HandleFunc("GET /{$}", s.home)
+	s.mux.HandleFunc("GET /board", s.board)
+	s.mux.HandleFunc("GET /search", s.search)
+	s.mux.HandleFunc("GET /tasks", s.taskList)
+	s.mux.HandleFunc("POST /tasks", protect(s.createTask))
+	s.mux.HandleFunc("GET /tasks/new", s.newTask)
+	s.mux.HandleFunc("GET /tasks/{ref}", s.taskDetail)
+	s.mux.HandleFunc("POST /tasks/{ref}", protect(s.updateTask))
+	s.mux.HandleFunc("PATCH /tasks/{ref}", protect(s.patchTask))
+	s.mux.HandleFunc("GET /tasks/{ref}/edit", s.editTask)
+	s.mux.Handle("GET /static/", StaticFileServer())
+	s.mux.HandleFunc("/", s.notFound)
+
+	return s, nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// ListenAndServe serves on addr until ctx is done, and then shuts the server
+// down gracefully.
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ctx, l)
+}
+
+// Serve serves on l until ctx is done. Requests in flight are given
+// ShutdownTimeout to finish.
+func (s *Server) Serve(ctx context.Context, l net.Listener) error {
+	srv := &http.Server{
+		Handler:           s,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- srv.Serve(l)
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+	defer cancel()
+	err := srv.Shutdown(shutdownCtx)
+	if err != nil {
+		return fmt.Errorf("unable to shut down: %w", err)
+	}
+	if err = <-errc; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// page is the data of index.html; View is passed on to the view. Query is
+// shown in the search box of the header.
+type page struct {
+	Title string
+	Query string
+	View  any
+}
+
+// item is a task along with the file it is stored in. Ref is the reference
+// used in the URL of the task.
+type item struct {
+	Ref  string
+	File string
+	Task *task.Task
+}
+
+func newItem(e backlog.Entry) item {
+	return item{
+		Ref:  backlog.Stem(e.File),
+		File: e.File,
+		Task: e.Task,
+	}
+}
+
+func (s *Server) home(w http.ResponseWriter, r *http.Request) {
+	entries, err := s.project.Entries()
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	data := struct {
+		Total int
+		Todo  int
+		Done  int
+		Next  []item
+	}{Total: len(entries)}
+	for _, e := range entries {
+		switch e.Task.Status {
+		case task.StatusDone:
+			data.Done++
+		default:
+			data.Todo++
+			if len(data.Next) < nextTasks {
+				data.Next = append(data.Next, newItem(e))
+			}
+		}
+	}
+
+	s.render(w, r, http.StatusOK, "home.tmpl", page{Title: "Home", View: data})
+}
+
+func (s *Server) taskList(w http.ResponseWriter, r *http.Request) {
+	entries, err := s.project.Entries()
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	items := make([]item, 0, len(entries))
+	for _, e := range entries {
+		items = append(items, newItem(e))
+	}
+
+	s.render(w, r, http.StatusOK, "task-list.tmpl", page{
+		Title: "Tasks",
+		View:  struct{ Tasks []item }{Tasks: items},
+	})
+}
+
+// board shows the tasks as cards in a column per status, in backlog order.
+func (s *Server) board(w http.ResponseWriter, r *http.Request) {
+	entries, err := s.project.Entries()
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+	token, err := csrfToken(w, r)
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	type column struct {
+		Status task.Status
+		Cards  []item
+	}
+	columns := make([]column, 0, len(task.Statuses))
+	for _, status := range task.Statuses {
+		c := column{Status: status}
+		for _, e := range entries {
+			if e.Task.Status == status {
+				c.Cards = append(c.Cards, newItem(e))
+			}
+		}
+		columns = append(columns, c)
+	}
+
+	s.render(w, r, http.StatusOK, "board.tmpl", page{
+		Title: "Board",
+		View: struct {
+			CSRF    string
+			Columns []column
+		}{CSRF: token, Columns: columns},
+	})
+}
+
+// search shows the tasks that match the query string: q is the text to look
+// for, status, priority and tag, which may be repeated, filter the tasks.
+func (s *Server) search(w http.ResponseWriter, r *http.Request) {
+	entries, err := s.project.Entries()
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+
+	values := r.URL.Query()
+	q := search.Query{
+		Text:     values.Get("q"),
+		Status:   task.Status(values.Get("status")),
+		Priority: task.Priority(values.Get("priority")),
+		Tags:     task.ParseTags(strings.Join(values["tag"], ",")),
+	}
+
+	tasks := make([]*task.Task, 0, len(entries))
+	refs := make(map[uuid.UUID]string, len(entries))
+	var tags []string
+	for _, e := range entries {
+		tasks = append(tasks, e.Task)
+		refs[e.Task.ID] = backlog.Stem(e.File)
+		for _, tag := range e.Task.Tags {
+			if !slices.Contains(tags, tag) {
+				tags = append(tags, tag)
+			}
+		}
+	}
+	slices.Sort(tags)
+
+	view := searchView{
+		Query:      q,
+		Tags:       tags,
+		Statuses:   task.Statuses,
+		Priorities: task.Priorities,
+	}
+	for _, res := range search.New(tasks).Search(q) {
+		view.Results = append(view.Results, searchResult{Result: res, Ref: refs[res.Task.ID]})
+	}
+
+	s.render(w, r, http.StatusOK, "search.tmpl", page{Title: "Search", Query: q.Text, View: view})
+}
+
+// searchView is the data of the search view. Tags lists every tag in use, to
+// filter on.
+type searchView struct {
+	Query      search.Query
+	Results    []searchResult
+	Tags       []string
+	Statuses   []task.Status
+	Priorities []task.Priority
+}
+
+// HasTag reports whether the query filters on tag.
+func (v searchView) HasTag(tag string) bool {
+	return slices.Contains(v.Query.Tags, tag)
+}
+
+type searchResult struct {
+	search.Result
+	Ref string
+}
+
+func (s *Server) taskDetail(w http.ResponseWriter, r *http.Request) {
+	it, ok := s.find(w, r)
+	if !ok {
+		return
+	}
+	s.render(w, r, http.StatusOK, "task.tmpl", page{Title: it.Task.Title, View: it})
+}
+
+func (s *Server) newTask(w http.ResponseWriter, r *http.Request) {
+	s.renderForm(w, r, http.StatusOK, newTaskForm("", nil))
+}
+
+func (s *Server) createTask(w http.ResponseWriter, r *http.Request) {
+	f := parseTaskForm("", r)
+
+	t, err := task.New(f.Title, f.Description)
+	if !f.check("Title", err) {
+		// Check the other fields as well, so every error is shown at once.
+		t = &task.Task{}
+	}
+	f.apply(t)
+	if !f.Valid() {
+		s.renderForm(w, r, http.StatusUnprocessableEntity, f)
+		return
+	}
+
+	err = s.project.Add(t)
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+	s.redirect(w, r, t)
+}
+
+func (s *Server) editTask(w http.ResponseWriter, r *http.Request) {
+	it, ok := s.find(w, r)
+	if !ok {
+		return
+	}
+	s.renderForm(w, r, http.StatusOK, newTaskForm(it.Ref, it.Task))
+}
+
+// updateTask saves the task form. The task is changed under the project lock,
+// and a form loaded before the task last changed is shown again with a warning
+// instead of undoing that change.
+func (s *Server) updateTask(w http.ResponseWriter, r *http.Request) {
+	it, ok := s.find(w, r)
+	if !ok {
+		return
+	}
+
+	f := parseTaskForm(it.Ref, r)
+	status := http.StatusUnprocessableEntity
+	t, err := s.project.Edit(it.Task.ID, func(t *task.Task) error {
+		if !f.current(t) {
+			status = http.StatusConflict
+			return errInvalidForm
+		}
+		f.check("Title", t.Update(f.Title, f.Description))
+		f.apply(t)
+		if !f.Valid() {
+			return errInvalidForm
+		}
+		return nil
+	})
+	switch {
+	case errors.Is(err, errInvalidForm):
+		s.renderForm(w, r, status, f)
+	case errors.Is(err, project.ErrNotFound):
+		s.notFound(w, r)
+	case err != nil:
+		s.fail(w, r, err)
+	default:
+		s.redirect(w, r, t)
+	}
+}
+
+// patchTask changes the fields of a task that are sent with the request,
+// which for now is only its status.
+func (s *Server) patchTask(w http.ResponseWriter, r *http.Request) {
+	it, ok := s.find(w, r)
+	if !ok {
+		return
+	}
+
+	status := task.Status(r.PostFormValue("status"))
+	if !status.Valid() {
+		http.Error(w, fmt.Sprintf("%s: %q", task.ErrStatus, status), http.StatusUnprocessableEntity)
+		return
+	}
+	if status != it.Task.Status {
+		_, err := s.project.SetStatus(it.Task.ID, status)
+		// The task may have been deleted since it was found.
+		if errors.Is(err, project.ErrNotFound) {
+			s.notFound(w, r)
+			return
+		}
+		if err != nil {
+			s.fail(w, r, err)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) renderForm(w http.ResponseWriter, r *http.Request, status int, f taskForm) {
+	token, err := csrfToken(w, r)
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+	f.CSRF = token
+
+	title := "New task"
+	if f.Ref != "" {
+		title = "Edit task"
+	}
+	s.render(w, r, status, "task-form.tmpl", page{Title: title, View: f})
+}
+
+// redirect sends the client to the page of t after it was saved, so
+// reloading that page does not send the form again.
+func (s *Server) redirect(w http.ResponseWriter, r *http.Request, t *task.Task) {
+	it, err := s.item(t.ID)
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+	http.Redirect(w, r, "/tasks/"+it.Ref, http.StatusSeeOther)
+}
+
+func (s *Server) notFound(w http.ResponseWriter, r *http.Request) {
+	s.render(w, r, http.StatusNotFound, "404.tmpl", page{Title: "Page not found"})
+}
+
+// find returns the task the ref in the path of r refers to. If there is no
+// such task the response has been written and ok is false.
+func (s *Server) find(w http.ResponseWriter, r *http.Request) (it item, ok bool) {
+	t, err := s.project.Find(r.PathValue("ref"))
+	if errors.Is(err, project.ErrNotFound) || errors.Is(err, project.ErrAmbiguous) {
+		s.notFound(w, r)
+		return item{}, false
+	}
+	if err != nil {
+		s.fail(w, r, err)
+		return item{}, false
+	}
+
+	it, err = s.item(t.ID)
+	if errors.Is(err, project.ErrNotFound) {
+		s.notFound(w, r)
+		return item{}, false
+	}
+	if err != nil {
+		s.fail(w, r, err)
+		return item{}, false
+	}
+	return it, true
+}
+
+// item returns the task with the given id along with its file.
+func (s *Server) item(id uuid.UUID) (item, error) {
+	entries, err := s.project.Entries()
+	if err != nil {
+		return item{}, err
+	}
+	for _, e := range entries {
+		if e.Task.ID == id {
+			return newItem(e), nil
+		}
+	}
+	return item{}, fmt.Errorf("%w: %s", project.ErrNotFound, id)
+}
+
+func (s *Server) render(w http.ResponseWriter, r *http.Request, status int, view string, data page) {
+	html, err := Render(s.pages[view], data)
+	if err != nil {
+		s.fail(w, r, fmt.Errorf("unable to render %s: %w", view, err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	_, _ = w.Write([]byte(html))
+}
+
+func (s *Server) fail(w http.ResponseWriter, r *http.Request, err error) {
+	slog.Error("request failed",
+		slog.String("method", r.Method),
+		slog.String("path", r.URL.Path),
+		slog.Any("error", err),
+	)
+	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+}
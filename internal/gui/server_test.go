@@ -0,0 +1,506 @@
+package gui
+
+import (
+	"context"
+	"errors"
+	"github.com/tedla-brandsema/tribble/internal/testenv"
+	"github.com/tedla-brandsema/tribble/project"
+	"github.com/tedla-brandsema/tribble/repo"
+	"github.com/tedla-brandsema/tribble/task"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testServer(t *testing.T) (*Server, *project.Project) {
+	t.Helper()
+
+	p, err := project.Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to open project: %v", err)
+	}
+	s, err := NewServer(p)
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	return s, p
+}
+
+func get(t *testing.T, h http.Handler, path string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	return rec
+}
+
+func post(t *testing.T, h http.Handler, path, token string, form url.Values) *httptest.ResponseRecorder {
+	t.Helper()
+
+	if token != "" {
+		form.Set(csrfField, token)
+	}
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(&http.Cookie{Name: csrfCookie, Value: token})
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestServer(t *testing.T) {
+	testenv.Isolate(t)
+
+	s, p := testServer(t)
+
+	first, err := p.Create("Write the docs", "All of them")
+	if err != nil {
+		t.Fatalf("Failed to create task: %v", err)
+	}
+	if _, err = p.Create("Fix <the> bug", ""); err != nil {
+		t.Fatalf("Failed to create task: %v", err)
+	}
+	ref := "write-the-docs-" + strings.ReplaceAll(first.ID.String(), "-", "")[:8]
+
+	tests := []struct {
+		name     string
+		path     string
+		status   int
+		expected []string
+	}{
+		{name: "Home", path: "/", status: http.StatusOK, expected: []string{"2 tasks, 2 to do and 0 done", "/tasks/" + ref}},
+		{name: "Task list", path: "/tasks", status: http.StatusOK, expected: []string{"Write the docs", "Fix &lt;the&gt; bug", "/tasks/" + ref}},
+		{name: "Task detail", path: "/tasks/" + ref, status: http.StatusOK, expected: []string{"<title>Write the docs - tribble</title>", "All of them", ref + ".md"}},
+		{name: "Task detail by id", path: "/tasks/" + first.ID.String(), status: http.StatusOK, expected: []string{"All of them"}},
+		{name: "New task", path: "/tasks/new", status: http.StatusOK, expected: []string{"New Task"}},
+		{name: "Edit task", path: "/tasks/" + ref + "/edit", status: http.StatusOK, expected: []string{"Edit Task", `value="Write the docs"`}},
+		{name: "Unknown task", path: "/tasks/nothing", status: http.StatusNotFound, expected: []string{"Page not found"}},
+		{name: "Unknown path", path: "/nothing/here", status: http.StatusNotFound, expected: []string{"Page not found"}},
+		{name: "Static", path: "/static/css/bootstrap.min.css", status: http.StatusOK, expected: []string{"Bootstrap"}},
+		{name: "Static directory", path: "/static/css/", status: http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rec := get(t, s, test.path)
+			if rec.Code != test.status {
+				t.Fatalf("Expected status %d, got %d", test.status, rec.Code)
+			}
+			body := rec.Body.String()
+			for _, expected := range test.expected {
+				if !strings.Contains(body, expected) {
+					t.Fatalf("Expected body to contain %q, got %s", expected, body)
+				}
+			}
+		})
+	}
+}
+
+func TestServe(t *testing.T) {
+	testenv.Isolate(t)
+
+	s, _ := testServer(t)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() {
+		errc <- s.Serve(ctx, l)
+	}()
+
+	resp, err := http.Get("http://" + l.Addr().String() + "/tasks")
+	if err != nil {
+		t.Fatalf("Failed to get tasks: %v", err)
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+
+	cancel()
+	select {
+	case err = <-errc:
+		if err != nil {
+			t.Fatalf("Expected a clean shutdown, got %v", err)
+		}
+	case <-time.After(ShutdownTimeout):
+		t.Fatalf("Expected the server to stop")
+	}
+
+	if _, err = http.Get("http://" + l.Addr().String() + "/"); err == nil {
+		t.Fatalf("Expected the server to be closed, got %v", err)
+	}
+}
+
+func TestTaskForm(t *testing.T) {
+	testenv.Isolate(t)
+
+	s, p := testServer(t)
+
+	rec := get(t, s, "/tasks/new")
+	var token string
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == csrfCookie {
+			token = c.Value
+		}
+	}
+	if token == "" || !strings.Contains(rec.Body.String(), `value="`+token+`"`) {
+		t.Fatalf("Expected a CSRF token in the cookie and the form, got %s", rec.Body.String())
+	}
+
+	valid := func() url.Values {
+		return url.Values{
+			"title":       {"Write the docs"},
+			"description": {"All *of* them"},
+			"status":      {"todo"},
+			"priority":    {"high"},
+			"tags":        {"docs, v1"},
+			"due":         {"2024-11-01"},
+		}
+	}
+
+	t.Run("Missing token", func(t *testing.T) {
+		rec := post(t, s, "/tasks", "", valid())
+		if rec.Code != http.StatusForbidden {
+			t.Fatalf("Expected status %d, got %d", http.StatusForbidden, rec.Code)
+		}
+	})
+
+	t.Run("Wrong token", func(t *testing.T) {
+		form := valid()
+		form.Set(csrfField, strings.Repeat("0", 2*csrfBytes))
+		req := httptest.NewRequest(http.MethodPost, "/tasks", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(&http.Cookie{Name: csrfCookie, Value: token})
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		if rec.Code != http.StatusForbidden {
+			t.Fatalf("Expected status %d, got %d", http.StatusForbidden, rec.Code)
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		form := valid()
+		form.Set("title", " ")
+		form.Set("priority", "urgent")
+		form.Set("due", "tomorrow")
+		rec := post(t, s, "/tasks", token, form)
+		if rec.Code != http.StatusUnprocessableEntity {
+			t.Fatalf("Expected status %d, got %d", http.StatusUnprocessableEntity, rec.Code)
+		}
+
+		body := rec.Body.String()
+		for _, expected := range []string{"task title is empty", "unknown task priority", "invalid task due date", `value="tomorrow"`, "All *of* them", `value="docs, v1"`} {
+			if !strings.Contains(body, expected) {
+				t.Fatalf("Expected body to contain %q, got %s", expected, body)
+			}
+		}
+		if tasks, _ := p.Tasks(); len(tasks) != 0 {
+			t.Fatalf("Expected no tasks, got %d", len(tasks))
+		}
+	})
+
+	var location string
+	t.Run("Create", func(t *testing.T) {
+		rec := post(t, s, "/tasks", token, valid())
+		if rec.Code != http.StatusSeeOther {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusSeeOther, rec.Code, rec.Body.String())
+		}
+
+		tasks, err := p.Tasks()
+		if err != nil || len(tasks) != 1 {
+			t.Fatalf("Expected one task, got %d: %v", len(tasks), err)
+		}
+		created := tasks[0]
+		if created.Priority != task.PriorityHigh || task.FormatDue(created.Due) != "2024-11-01" || strings.Join(created.Tags, ",") != "docs,v1" {
+			t.Fatalf("Expected the values of the form, got %+v", created)
+		}
+
+		location = rec.Header().Get("Location")
+		expected := "/tasks/write-the-docs-" + strings.ReplaceAll(created.ID.String(), "-", "")[:8]
+		if location != expected {
+			t.Fatalf("Expected redirect to %s, got %s", expected, location)
+		}
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		form := valid()
+		form.Set("title", "Write the manual")
+		form.Set("status", "done")
+		form.Set("due", "")
+		rec := post(t, s, location, token, form)
+		if rec.Code != http.StatusSeeOther {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusSeeOther, rec.Code, rec.Body.String())
+		}
+
+		tasks, err := p.Tasks()
+		if err != nil || len(tasks) != 1 {
+			t.Fatalf("Expected one task, got %d: %v", len(tasks), err)
+		}
+		updated := tasks[0]
+		if updated.Title != "Write the manual" || !updated.Done() || !updated.Due.IsZero() {
+			t.Fatalf("Expected the values of the form, got %+v", updated)
+		}
+		if !strings.HasPrefix(rec.Header().Get("Location"), "/tasks/write-the-manual-") {
+			t.Fatalf("Expected redirect to the renamed task, got %s", rec.Header().Get("Location"))
+		}
+		location = rec.Header().Get("Location")
+	})
+
+	t.Run("Line endings", func(t *testing.T) {
+		form := valid()
+		form.Set("title", "Write the manual")
+		form.Set("description", "First line\r\n\r\n* A list\r\n")
+		rec := post(t, s, location, token, form)
+		if rec.Code != http.StatusSeeOther {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusSeeOther, rec.Code, rec.Body.String())
+		}
+
+		tasks, err := p.Tasks()
+		if err != nil || len(tasks) != 1 {
+			t.Fatalf("Expected one task, got %d: %v", len(tasks), err)
+		}
+		if expected := "First line\n\n* A list"; tasks[0].Description != expected {
+			t.Fatalf("Expected description %q, got %q", expected, tasks[0].Description)
+		}
+		location = rec.Header().Get("Location")
+	})
+
+	t.Run("Stale form", func(t *testing.T) {
+		before, err := p.Tasks()
+		if err != nil {
+			t.Fatalf("Failed to read tasks: %v", err)
+		}
+		current := before[0].Modified.Format(time.RFC3339)
+
+		form := valid()
+		form.Set("modified", "2024-10-01T12:00:00Z")
+		rec := post(t, s, location, token, form)
+		if rec.Code != http.StatusConflict {
+			t.Fatalf("Expected status %d, got %d", http.StatusConflict, rec.Code)
+		}
+		body := rec.Body.String()
+		for _, expected := range []string{"was changed since this form was loaded", `name="modified" value="` + current + `"`, `value="Write the docs"`} {
+			if !strings.Contains(body, expected) {
+				t.Fatalf("Expected body to contain %q, got %s", expected, body)
+			}
+		}
+		if after, _ := p.Tasks(); !after[0].Equal(before[0]) {
+			t.Fatalf("Expected the task to be left alone, got %+v", after[0])
+		}
+
+		form.Set("modified", current)
+		rec = post(t, s, location, token, form)
+		if rec.Code != http.StatusSeeOther {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusSeeOther, rec.Code, rec.Body.String())
+		}
+	})
+
+	t.Run("Update unknown task", func(t *testing.T) {
+		rec := post(t, s, "/tasks/nothing", token, valid())
+		if rec.Code != http.StatusNotFound {
+			t.Fatalf("Expected status %d, got %d", http.StatusNotFound, rec.Code)
+		}
+	})
+}
+
+func TestSearch(t *testing.T) {
+	testenv.Isolate(t)
+
+	s, p := testServer(t)
+
+	for _, title := range []string{"Write the docs", "Fix the login page", "Document the API"} {
+		created, err := p.Create(title, "Mentions the <docs> somewhere.")
+		if err != nil {
+			t.Fatalf("Failed to create task: %v", err)
+		}
+		if err = created.SetTags([]string{"docs"}); err != nil {
+			t.Fatalf("Failed to set tags: %v", err)
+		}
+		if title == "Write the docs" {
+			err = created.SetStatus(task.StatusDone)
+			if err != nil {
+				t.Fatalf("Failed to set status: %v", err)
+			}
+		}
+		if err = p.Update(created); err != nil {
+			t.Fatalf("Failed to update task: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		path       string
+		expected   []string
+		unexpected []string
+	}{
+		{
+			name:     "Ranked and highlighted",
+			path:     "/search?q=docs",
+			expected: []string{"3 tasks found", "Write the <mark>docs</mark>", "Mentions the &lt;<mark>docs</mark>&gt; somewhere.", `name="q" value="docs"`},
+		},
+		{
+			name:       "Status filter",
+			path:       "/search?q=docs&status=todo",
+			expected:   []string{"2 tasks found", `<option value="todo" selected>`},
+			unexpected: []string{"Write the"},
+		},
+		{
+			name:     "Tag filter",
+			path:     "/search?tag=docs&q=api",
+			expected: []string{"1 task found", "Document the <mark>API</mark>", `<option value="docs" selected>`},
+		},
+		{
+			name:     "No match",
+			path:     "/search?q=deploy",
+			expected: []string{"0 tasks found"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rec := get(t, s, test.path)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("Expected status %d, got %d", http.StatusOK, rec.Code)
+			}
+			body := rec.Body.String()
+			for _, expected := range test.expected {
+				if !strings.Contains(body, expected) {
+					t.Fatalf("Expected body to contain %q, got %s", expected, body)
+				}
+			}
+			for _, unexpected := range test.unexpected {
+				if strings.Contains(body, unexpected) {
+					t.Fatalf("Expected body not to contain %q, got %s", unexpected, body)
+				}
+			}
+		})
+	}
+
+	t.Run("Ranking", func(t *testing.T) {
+		body := get(t, s, "/search?q=docs").Body.String()
+		if strings.Index(body, "Write the <mark>docs</mark>") > strings.Index(body, "Fix the login page") {
+			t.Fatalf("Expected the match in the title first, got %s", body)
+		}
+	})
+}
+
+func TestBoard(t *testing.T) {
+	testenv.Isolate(t)
+
+	s, p := testServer(t)
+
+	first, err := p.Create("Write the docs", "")
+	if err != nil {
+		t.Fatalf("Failed to create task: %v", err)
+	}
+	if _, err = p.Create("Fix the bug", ""); err != nil {
+		t.Fatalf("Failed to create task: %v", err)
+	}
+	ref := "write-the-docs-" + strings.ReplaceAll(first.ID.String(), "-", "")[:8]
+
+	rec := get(t, s, "/board")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	body := rec.Body.String()
+	for _, expected := range []string{`data-status="todo"`, `data-status="done"`, `data-ref="` + ref + `"`, "Fix the bug"} {
+		if !strings.Contains(body, expected) {
+			t.Fatalf("Expected body to contain %q, got %s", expected, body)
+		}
+	}
+	var token string
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == csrfCookie {
+			token = c.Value
+		}
+	}
+	if token == "" || !strings.Contains(body, `data-csrf="`+token+`"`) {
+		t.Fatalf("Expected the CSRF token on the board, got %s", body)
+	}
+
+	patch := func(t *testing.T, path, token, status string) *httptest.ResponseRecorder {
+		t.Helper()
+
+		req := httptest.NewRequest(http.MethodPatch, path, strings.NewReader(url.Values{"status": {status}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set(csrfHeader, token)
+		req.AddCookie(&http.Cookie{Name: csrfCookie, Value: token})
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		return rec
+	}
+
+	tests := []struct {
+		name   string
+		path   string
+		token  string
+		status string
+		code   int
+	}{
+		{name: "Missing token", path: "/tasks/" + ref, status: "done", code: http.StatusForbidden},
+		{name: "Unknown status", path: "/tasks/" + ref, token: token, status: "blocked", code: http.StatusUnprocessableEntity},
+		{name: "Unknown task", path: "/tasks/nothing", token: token, status: "done", code: http.StatusNotFound},
+		{name: "Move", path: "/tasks/" + ref, token: token, status: "done", code: http.StatusNoContent},
+		{name: "Move to the same column", path: "/tasks/" + ref, token: token, status: "done", code: http.StatusNoContent},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if rec := patch(t, test.path, test.token, test.status); rec.Code != test.code {
+				t.Fatalf("Expected status %d, got %d: %s", test.code, rec.Code, rec.Body.String())
+			}
+		})
+	}
+
+	t.Run("Persisted", func(t *testing.T) {
+		moved, err := p.Task(first.ID)
+		if err != nil {
+			t.Fatalf("Failed to read task: %v", err)
+		}
+		if !moved.Done() {
+			t.Fatalf("Expected the task to be done, got %s", moved.Status)
+		}
+
+		history, err := p.History(first.ID)
+		if err != nil {
+			t.Fatalf("Failed to read history: %v", err)
+		}
+		if len(history) != 2 || history[0].Action != repo.ActionUpdate {
+			t.Fatalf("Expected one commit for the move, got %+v", history)
+		}
+
+		body := get(t, s, "/board").Body.String()
+		done := strings.Index(body, `data-status="done"`)
+		if i := strings.Index(body, `data-ref="`+ref+`"`); i < done {
+			t.Fatalf("Expected the task in the done column, got %s", body)
+		}
+	})
+
+	t.Run("Deleted after it was found", func(t *testing.T) {
+		// Hand edits to the markdown backlog are imported just before the
+		// status is saved, so emptying it deletes the task after the request
+		// found it.
+		if err := os.WriteFile(p.Config().BacklogFile(), nil, 0644); err != nil {
+			t.Fatalf("Failed to write backlog: %v", err)
+		}
+		if rec := patch(t, "/tasks/"+ref, token, "todo"); rec.Code != http.StatusNotFound {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusNotFound, rec.Code, rec.Body.String())
+		}
+		if _, err := p.Task(first.ID); !errors.Is(err, project.ErrNotFound) {
+			t.Fatalf("Expected error %v, got %v", project.ErrNotFound, err)
+		}
+	})
+}
@@ -0,0 +1,215 @@
+package search
+
+import (
+	"cmp"
+	"github.com/tedla-brandsema/tribble/task"
+	"math"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+type field int
+
+const (
+	fieldTitle field = iota
+	fieldTags
+	fieldDescription
+	fields
+)
+
+// weights tells how much a match in each field counts: a term in the title
+// says more about a task than the same term somewhere in its description.
+var weights = [fields]float64{
+	fieldTitle:       3,
+	fieldTags:        2,
+	fieldDescription: 1,
+}
+
+// prefixWeight is the part of the score a term gets for matching only the
+// start of a word, so "doc" finds "docs" but ranks it below "doc".
+const prefixWeight = 0.5
+
+type posting struct {
+	doc    int
+	counts [fields]int
+}
+
+// Index is a full-text index of the title, tags and description of a list of
+// tasks.
+type Index struct {
+	tasks    []*task.Task
+	postings map[string][]posting
+	// terms are the keys of postings in order, to find the terms that start
+	// with a prefix.
+	terms []string
+}
+
+// New returns an index of tasks. Results of the same score keep the order of
+// tasks.
+func New(tasks []*task.Task) *Index {
+	ix := &Index{
+		tasks:    tasks,
+		postings: make(map[string][]posting),
+	}
+
+	for doc, t := range tasks {
+		counts := make(map[string]*[fields]int)
+		add := func(f field, text string) {
+			for _, tok := range tokenize(text) {
+				c, ok := counts[tok.term]
+				if !ok {
+					c = new([fields]int)
+					counts[tok.term] = c
+				}
+				c[f]++
+			}
+		}
+		add(fieldTitle, t.Title)
+		add(fieldTags, strings.Join(t.Tags, " "))
+		add(fieldDescription, t.Description)
+
+		for term, c := range counts {
+			ix.postings[term] = append(ix.postings[term], posting{doc: doc, counts: *c})
+		}
+	}
+
+	ix.terms = make([]string, 0, len(ix.postings))
+	for term := range ix.postings {
+		ix.terms = append(ix.terms, term)
+	}
+	slices.Sort(ix.terms)
+	return ix
+}
+
+// Query selects tasks. Every word of Text has to occur in the title, tags or
+// description of a task, as a word or the start of one. The other fields
+// filter the tasks; empty fields match every task.
+type Query struct {
+	Text     string
+	Status   task.Status
+	Priority task.Priority
+	Tags     []string
+}
+
+// Terms returns the words of the text of q.
+func (q Query) Terms() []string {
+	var terms []string
+	for _, tok := range tokenize(q.Text) {
+		if !slices.Contains(terms, tok.term) {
+			terms = append(terms, tok.term)
+		}
+	}
+	return terms
+}
+
+func (q Query) match(t *task.Task) bool {
+	if q.Status != "" && t.Status != q.Status {
+		return false
+	}
+	if q.Priority != "" && t.Priority != q.Priority {
+		return false
+	}
+	for _, tag := range q.Tags {
+		if !t.HasTag(tag) {
+			return false
+		}
+	}
+	return true
+}
+
+// Result is a task found by a query. Title is the title of the task and
+// Snippet the part of its description around the first match, both split into
+// fragments so the matching words can be highlighted.
+type Result struct {
+	Task    *task.Task
+	Score   float64
+	Title   []Fragment
+	Snippet []Fragment
+}
+
+// Search returns the tasks that match q, the best match first. Without text
+// every task that passes the filters is returned in order.
+func (ix *Index) Search(q Query) []Result {
+	terms := q.Terms()
+
+	scores := make([]float64, len(ix.tasks))
+	matched := make([]int, len(ix.tasks))
+	for _, term := range terms {
+		found := make(map[int]float64)
+		for _, indexed := range ix.prefixed(term) {
+			ps := ix.postings[indexed]
+			idf := math.Log(1 + float64(len(ix.tasks))/float64(len(ps)))
+			weight := 1.0
+			if indexed != term {
+				weight = prefixWeight
+			}
+
+			for _, p := range ps {
+				var tf float64
+				for f, n := range p.counts {
+					tf += weights[f] * float64(n)
+				}
+				found[p.doc] = max(found[p.doc], weight*(1+math.Log(tf))*idf)
+			}
+		}
+
+		for doc, score := range found {
+			scores[doc] += score
+			matched[doc]++
+		}
+	}
+
+	var results []Result
+	for doc, t := range ix.tasks {
+		if matched[doc] < len(terms) || !q.match(t) {
+			continue
+		}
+		results = append(results, Result{
+			Task:    t,
+			Score:   scores[doc],
+			Title:   Highlight(t.Title, terms),
+			Snippet: Snippet(t.Description, terms, snippetLength),
+		})
+	}
+
+	slices.SortStableFunc(results, func(a, b Result) int {
+		return cmp.Compare(b.Score, a.Score)
+	})
+	return results
+}
+
+// prefixed returns the indexed terms that start with prefix.
+func (ix *Index) prefixed(prefix string) []string {
+	i, _ := slices.BinarySearch(ix.terms, prefix)
+	j := i
+	for j < len(ix.terms) && strings.HasPrefix(ix.terms[j], prefix) {
+		j++
+	}
+	return ix.terms[i:j]
+}
+
+// token is a word of a text: its lower case form and where it is.
+type token struct {
+	term       string
+	start, end int
+}
+
+func tokenize(text string) []token {
+	var tokens []token
+	start := -1
+	for i, r := range text {
+		word := unicode.IsLetter(r) || unicode.IsDigit(r)
+		switch {
+		case word && start < 0:
+			start = i
+		case !word && start >= 0:
+			tokens = append(tokens, token{term: strings.ToLower(text[start:i]), start: start, end: i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, token{term: strings.ToLower(text[start:]), start: start, end: len(text)})
+	}
+	return tokens
+}
@@ -0,0 +1,87 @@
+package search
+
+import (
+	"github.com/google/uuid"
+	"github.com/tedla-brandsema/tribble/task"
+	"strings"
+	"testing"
+)
+
+func testTasks() []*task.Task {
+	return []*task.Task{
+		{ID: uuid.New(), Title: "Fix the login page", Status: task.StatusTodo, Tags: []string{"ui"}, Description: "The docs say the button is blue."},
+		{ID: uuid.New(), Title: "Write the docs", Status: task.StatusDone, Tags: []string{"docs"}, Priority: task.PriorityHigh},
+		{ID: uuid.New(), Title: "Document the API", Status: task.StatusTodo, Tags: []string{"docs", "api"}, Description: "Every endpoint of the API."},
+		{ID: uuid.New(), Title: "Release", Status: task.StatusTodo},
+	}
+}
+
+func titles(results []Result) string {
+	var s []string
+	for _, r := range results {
+		s = append(s, r.Task.Title)
+	}
+	return strings.Join(s, "|")
+}
+
+func TestSearch(t *testing.T) {
+	ix := New(testTasks())
+
+	tests := []struct {
+		name     string
+		query    Query
+		expected string
+	}{
+		{name: "Title before description", query: Query{Text: "docs"}, expected: "Write the docs|Document the API|Fix the login page"},
+		{name: "Prefix", query: Query{Text: "doc"}, expected: "Document the API|Write the docs|Fix the login page"},
+		{name: "Every word", query: Query{Text: "the API"}, expected: "Document the API"},
+		{name: "Case", query: Query{Text: "LOGIN"}, expected: "Fix the login page"},
+		{name: "No match", query: Query{Text: "deploy"}, expected: ""},
+		{name: "Status", query: Query{Text: "docs", Status: task.StatusTodo}, expected: "Document the API|Fix the login page"},
+		{name: "Priority", query: Query{Priority: task.PriorityHigh}, expected: "Write the docs"},
+		{name: "Tags", query: Query{Tags: []string{"docs", "api"}}, expected: "Document the API"},
+		{name: "Without text", query: Query{}, expected: "Fix the login page|Write the docs|Document the API|Release"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := titles(ix.Search(test.query)); got != test.expected {
+				t.Fatalf("Expected %q, got %q", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		terms    []string
+		length   int
+		expected string
+	}{
+		{name: "Whole text", text: "The docs say\nthe button is blue.", terms: []string{"button"}, length: 100, expected: "The docs say the [button] is blue."},
+		{name: "Around the match", text: "one two three four five six seven eight nine ten", terms: []string{"six"}, length: 20, expected: "…five [six] seven eight…"},
+		{name: "Start without a match", text: "one two three four five", terms: []string{"ten"}, length: 10, expected: "one two…"},
+		{name: "Prefix", text: "Document the docs", terms: []string{"doc"}, length: 100, expected: "[Document] the [docs]"},
+		{name: "Empty", text: " ", terms: []string{"doc"}, length: 100, expected: ""},
+		{name: "Match at the end of a long word", text: strings.Repeat("a", 100) + "/needle more words here", terms: []string{"needle"}, length: snippetLength, expected: "…" + strings.Repeat("a", 49) + "/[needle] more words here"},
+		{name: "Long word after the match", text: strings.Repeat("x", 60) + " " + strings.Repeat("b", 300), terms: []string{"bbb"}, length: snippetLength, expected: "…[" + strings.Repeat("b", snippetLength) + "]…"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var b strings.Builder
+			for _, f := range Snippet(test.text, test.terms, test.length) {
+				if f.Match {
+					b.WriteString("[" + f.Text + "]")
+				} else {
+					b.WriteString(f.Text)
+				}
+			}
+			if b.String() != test.expected {
+				t.Fatalf("Expected %q, got %q", test.expected, b.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package search
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// snippetLength is the number of bytes of a description shown with a result,
+// rounded to whole words.
+const snippetLength = 200
+
+// ellipsis marks a snippet that does not start or end where its text does.
+const ellipsis = "…"
+
+// Fragment is a part of a text; Match tells whether it is a word that matched
+// the query.
+type Fragment struct {
+	Text  string
+	Match bool
+}
+
+// Highlight splits text into fragments, marking the words that are one of
+// terms or start with one.
+func Highlight(text string, terms []string) []Fragment {
+	var fragments []Fragment
+	at := 0
+	for _, tok := range tokenize(text) {
+		if !matches(tok.term, terms) {
+			continue
+		}
+		if tok.start > at {
+			fragments = append(fragments, Fragment{Text: text[at:tok.start]})
+		}
+		fragments = append(fragments, Fragment{Text: text[tok.start:tok.end], Match: true})
+		at = tok.end
+	}
+	if at < len(text) {
+		fragments = append(fragments, Fragment{Text: text[at:]})
+	}
+	return fragments
+}
+
+// Snippet returns about length bytes of text around its first word that
+// matches one of terms, highlighted as by Highlight. Without a match the
+// snippet is the start of text.
+func Snippet(text string, terms []string, length int) []Fragment {
+	text = strings.Join(strings.Fields(text), " ")
+	if text == "" {
+		return nil
+	}
+
+	start := 0
+	for _, tok := range tokenize(text) {
+		if matches(tok.term, terms) {
+			// Show some of the words before the match.
+			start = wordStart(text, max(0, tok.start-length/4), tok.start)
+			break
+		}
+	}
+	end := len(text)
+	if start+length < end {
+		end = wordEnd(text, start, start+length)
+	}
+
+	fragments := Highlight(text[start:end], terms)
+	if start > 0 {
+		fragments = append([]Fragment{{Text: ellipsis}}, fragments...)
+	}
+	if end < len(text) {
+		fragments = append(fragments, Fragment{Text: ellipsis})
+	}
+	return fragments
+}
+
+func matches(word string, terms []string) bool {
+	for _, term := range terms {
+		if strings.HasPrefix(word, term) {
+			return true
+		}
+	}
+	return false
+}
+
+// wordStart returns the start of the word after i, or i itself if a word
+// starts there or no word starts between i and limit.
+func wordStart(text string, i, limit int) int {
+	for i > 0 && !utf8.RuneStart(text[i]) {
+		i--
+	}
+	if i == 0 || text[i-1] == ' ' {
+		return i
+	}
+	if j := strings.IndexByte(text[i:], ' '); j >= 0 && i+j+1 <= limit {
+		return i + j + 1
+	}
+	return i
+}
+
+// wordEnd returns the end of the last word of text[start:] that ends at or
+// before i, or i itself if a word ends there or no word of text[start:i] does.
+func wordEnd(text string, start, i int) int {
+	for i < len(text) && !utf8.RuneStart(text[i]) {
+		i++
+	}
+	if i == len(text) || text[i] == ' ' {
+		return i
+	}
+	if j := strings.LastIndexByte(text[start:i], ' '); j > 0 {
+		return start + j
+	}
+	return i
+}
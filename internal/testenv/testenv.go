@@ -0,0 +1,31 @@
+// Package testenv keeps tests from reading the settings of the developer who
+// runs them.
+package testenv
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Isolate points the user config directory at an empty directory and unsets
+// every TRIBBLE_* variable until the test ends.
+func Isolate(t testing.TB) {
+	t.Helper()
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	for _, env := range os.Environ() {
+		name, _, _ := strings.Cut(env, "=")
+		if !strings.HasPrefix(name, "TRIBBLE_") {
+			continue
+		}
+		// Setenv restores the variable when the test ends.
+		t.Setenv(name, "")
+		if err := os.Unsetenv(name); err != nil {
+			t.Fatalf("Failed to unset %s: %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,312 @@
+package project
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/tedla-brandsema/tribble/backlog"
+	"github.com/tedla-brandsema/tribble/config"
+	"github.com/tedla-brandsema/tribble/internal/fio"
+	"github.com/tedla-brandsema/tribble/repo"
+	"github.com/tedla-brandsema/tribble/task"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+const conflictsFile = "conflicts.json"
+
+var ErrNoConflict = errors.New("no such conflict")
+
+// mergeDriver merges task files per task and per field instead of per line, so
+// a merge never leaves conflict markers in a task file. Fields both sides
+// changed keep our value and are collected in conflicts to be resolved later.
+func mergeDriver(conflicts *[]task.Conflict) repo.MergeDriver {
+	return func(file string, base, ours, theirs []byte) ([]byte, error) {
+		if file == conflictsFile {
+			return mergeConflictFiles(base, ours, theirs)
+		}
+
+		dir, name := path.Split(file)
+		if dir == config.TasksFolder+"/" {
+			if name == backlog.OrderFile {
+				return backlog.MergeOrder(base, ours, theirs), nil
+			}
+			for _, c := range codecs {
+				if path.Ext(name) == c.Ext() {
+					return mergeTasks(c, conflicts, base, ours, theirs)
+				}
+			}
+			return nil, repo.ErrMergeConflict
+		}
+
+		for _, c := range codecs {
+			if file != tasksFile+c.Ext() {
+				continue
+			}
+			// A task file removed on one side means the serialize mode was
+			// changed there, which has to be sorted out by hand.
+			if ours == nil || theirs == nil {
+				return nil, repo.ErrMergeConflict
+			}
+			return mergeTasks(c, conflicts, base, ours, theirs)
+		}
+
+		return nil, repo.ErrMergeConflict
+	}
+}
+
+// mergeTasks merges the tasks in a task file. A file that is missing on one
+// side holds no tasks there; if no task is left the file is removed.
+func mergeTasks(c backlog.Codec, conflicts *[]task.Conflict, base, ours, theirs []byte) ([]byte, error) {
+	var sides [3][]*task.Task
+	for i, content := range [][]byte{base, ours, theirs} {
+		if content == nil {
+			continue
+		}
+		tasks, err := c.Decode(bytes.NewReader(content))
+		if err != nil {
+			return nil, err
+		}
+		sides[i] = tasks
+	}
+
+	merged, found := task.MergeList(sides[0], sides[1], sides[2])
+	*conflicts = append(*conflicts, found...)
+	if len(merged) == 0 {
+		return nil, nil
+	}
+
+	var b []byte
+	buff := bytes.NewBuffer(b)
+	err := c.Encode(buff, merged)
+	if err != nil {
+		return nil, err
+	}
+	return buff.Bytes(), nil
+}
+
+// mergeKey matches task files by the id in their name, so a task renamed on
+// one side is merged with the same task on the other side.
+func mergeKey(file string) string {
+	dir, name := path.Split(file)
+	if dir != config.TasksFolder+"/" || name == backlog.OrderFile {
+		return file
+	}
+	return dir + backlog.FileID(name) + path.Ext(name)
+}
+
+// mergeConflictFiles keeps the conflicts that are unresolved on both sides
+// and the conflicts either side added.
+func mergeConflictFiles(base, ours, theirs []byte) ([]byte, error) {
+	var sides [3][]task.Conflict
+	for i, content := range [][]byte{base, ours, theirs} {
+		if content == nil {
+			continue
+		}
+		err := json.Unmarshal(content, &sides[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+	b, o, t := sides[0], sides[1], sides[2]
+
+	var merged []task.Conflict
+	for _, c := range slices.Concat(o, t) {
+		if slices.Contains(merged, c) {
+			continue
+		}
+		if slices.Contains(b, c) && !(slices.Contains(o, c) && slices.Contains(t, c)) {
+			continue
+		}
+		merged = append(merged, c)
+	}
+
+	if len(merged) == 0 {
+		return nil, nil
+	}
+	return encodeConflicts(merged)
+}
+
+// Conflicts returns the conflicts merges left to be resolved.
+func (p *Project) Conflicts() ([]task.Conflict, error) {
+	var conflicts []task.Conflict
+
+	err := p.withLock(fio.SharedLock, func() error {
+		var err error
+		conflicts, err = p.readConflicts()
+		return err
+	})
+
+	return conflicts, err
+}
+
+// Conflict returns the conflict in the given field of a task.
+func (p *Project) Conflict(id uuid.UUID, field string) (task.Conflict, error) {
+	conflicts, err := p.Conflicts()
+	if err != nil {
+		return task.Conflict{}, err
+	}
+
+	i := indexOfConflict(conflicts, id, field)
+	if i < 0 {
+		return task.Conflict{}, fmt.Errorf("%w: %s of %s", ErrNoConflict, field, id)
+	}
+	return conflicts[i], nil
+}
+
+// Resolve settles a conflict by setting the field of the task to value, which
+// is usually the Ours or Theirs value of the conflict. A conflict on
+// task.FieldTask is resolved with task.Deleted, which deletes the task, or
+// task.Modified, which keeps it.
+func (p *Project) Resolve(id uuid.UUID, field, value string) error {
+	return p.write(func() error {
+		conflicts, err := p.readConflicts()
+		if err != nil {
+			return err
+		}
+		c := indexOfConflict(conflicts, id, field)
+		if c < 0 {
+			return fmt.Errorf("%w: %s of %s", ErrNoConflict, field, id)
+		}
+
+		tasks, err := p.tasks.Load()
+		if err != nil {
+			return err
+		}
+		i := indexOf(tasks, id)
+		if i < 0 && !(field == task.FieldTask && value == task.Deleted) {
+			return fmt.Errorf("%w: %s", ErrNotFound, id)
+		}
+
+		change := repo.Change{Action: repo.ActionResolve, TaskID: id}
+		if i >= 0 {
+			change.Title = tasks[i].Title
+		}
+
+		switch field {
+		case task.FieldTask:
+			switch value {
+			case task.Deleted:
+				if i >= 0 {
+					tasks = append(tasks[:i], tasks[i+1:]...)
+				}
+			case task.Modified:
+			default:
+				return fmt.Errorf("expected %q or %q, got %q", task.Deleted, task.Modified, value)
+			}
+		case "Title":
+			err = tasks[i].SetTitle(value)
+			change.Title = tasks[i].Title
+		case "Description":
+			tasks[i].SetDescription(value)
+		case "Status":
+			err = tasks[i].SetStatus(task.Status(value))
+		case "Tags":
+			err = tasks[i].SetTags(task.ParseTags(value))
+		case "Priority":
+			err = tasks[i].SetPriority(task.Priority(value))
+		case "Due":
+			var due time.Time
+			due, err = task.ParseDue(value)
+			if err == nil {
+				tasks[i].SetDue(due)
+			}
+		default:
+			return fmt.Errorf("unknown task field %q", field)
+		}
+		if err != nil {
+			return err
+		}
+
+		err = p.save(tasks)
+		if err != nil {
+			return err
+		}
+		err = p.writeConflicts(slices.Delete(conflicts, c, c+1))
+		if err != nil {
+			return err
+		}
+		return p.commit(change, conflictsFile)
+	})
+}
+
+// recordConflicts adds the conflicts of a merge to the conflicts that are
+// already waiting to be resolved and commits them.
+func (p *Project) recordConflicts(found []task.Conflict) error {
+	conflicts, err := p.readConflicts()
+	if err != nil {
+		return err
+	}
+
+	for _, c := range found {
+		if i := indexOfConflict(conflicts, c.TaskID, c.Field); i >= 0 {
+			conflicts[i] = c
+			continue
+		}
+		conflicts = append(conflicts, c)
+	}
+
+	err = p.writeConflicts(conflicts)
+	if err != nil {
+		return err
+	}
+	return p.commit(repo.Change{Action: repo.ActionConflict, Title: fmt.Sprintf("%d unresolved", len(conflicts))}, conflictsFile)
+}
+
+func (p *Project) conflictsPath() string {
+	return filepath.Join(p.cfg.TribblePath(), conflictsFile)
+}
+
+func (p *Project) readConflicts() ([]task.Conflict, error) {
+	path := p.conflictsPath()
+	if !p.cfg.FS().FileExists(path) {
+		return nil, nil
+	}
+
+	content, err := p.cfg.FS().ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var conflicts []task.Conflict
+	err = json.Unmarshal(content, &conflicts)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return conflicts, nil
+}
+
+// writeConflicts stores the conflicts, removing the file once all of them
+// are resolved.
+func (p *Project) writeConflicts(conflicts []task.Conflict) error {
+	path := p.conflictsPath()
+
+	if len(conflicts) == 0 {
+		err := p.cfg.FS().Remove(path)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	content, err := encodeConflicts(conflicts)
+	if err != nil {
+		return err
+	}
+	return p.cfg.FS().OverwriteFile(path, content)
+}
+
+func encodeConflicts(conflicts []task.Conflict) ([]byte, error) {
+	return json.MarshalIndent(conflicts, "", "\t")
+}
+
+func indexOfConflict(conflicts []task.Conflict, id uuid.UUID, field string) int {
+	return slices.IndexFunc(conflicts, func(c task.Conflict) bool {
+		return c.TaskID == id && c.Field == field
+	})
+}
@@ -0,0 +1,202 @@
+package project
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/google/uuid"
+	"github.com/tedla-brandsema/tribble/backlog"
+	"github.com/tedla-brandsema/tribble/config"
+	"github.com/tedla-brandsema/tribble/repo"
+	"github.com/tedla-brandsema/tribble/task"
+	"path"
+)
+
+// codecs lists every codec a task file may have been written with, so older
+// revisions can be read regardless of the serialize mode at the time.
+var codecs = []backlog.Codec{backlog.Markdown, backlog.JSON, backlog.Binary}
+
+// Revision is a revision of the repository that changed a single task.
+type Revision struct {
+	repo.Revision
+	// Action tells whether the task was created, updated or deleted.
+	Action repo.Action
+	// Task is the task as it was after the revision, nil if it was deleted.
+	Task *task.Task
+	// Changes lists the fields changed by the revision.
+	Changes []task.FieldChange
+}
+
+// History returns the revisions that changed the task with the given id,
+// newest first. Only the revisions that changed its task file, under any of the
+// names it had, are read.
+func (p *Project) History(id uuid.UUID) ([]Revision, error) {
+	log, err := p.repo.LogPaths(func(path string) bool {
+		return isTaskPath(path, id)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make(map[plumbing.Hash]*task.Task)
+	var history []Revision
+
+	for _, rev := range log {
+		after, err := p.version(versions, rev.Hash, id)
+		if err != nil {
+			return nil, err
+		}
+
+		var before *task.Task
+		if len(rev.Parents) > 0 {
+			before, err = p.version(versions, rev.Parents[0], id)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		changes := task.Diff(before, after)
+		if len(changes) == 0 {
+			continue
+		}
+
+		action := repo.ActionUpdate
+		switch {
+		case before == nil:
+			action = repo.ActionCreate
+		case after == nil:
+			action = repo.ActionDelete
+		}
+
+		history = append(history, Revision{
+			Revision: rev,
+			Action:   action,
+			Task:     after,
+			Changes:  changes,
+		})
+	}
+
+	return history, nil
+}
+
+// isTaskPath reports whether path, relative to the tribble folder, may hold the
+// task with the given id: its task file, or the single task file of older
+// revisions.
+func isTaskPath(file string, id uuid.UUID) bool {
+	dir, name := path.Split(file)
+	if dir == config.TasksFolder+"/" {
+		return backlog.IsFileOf(name, id)
+	}
+	return dir == "" && backlog.Stem(name) == tasksFile
+}
+
+// TaskAt returns the task with the given id as it was in the revision rev. It
+// returns ErrNotFound if the task did not exist in that revision.
+func (p *Project) TaskAt(id uuid.UUID, rev string) (*task.Task, error) {
+	r, err := p.repo.Resolve(rev)
+	if err != nil {
+		return nil, err
+	}
+
+	tasks, err := p.tasksAt(r.Hash)
+	if err != nil {
+		return nil, err
+	}
+
+	t := find(tasks, id)
+	if t == nil {
+		return nil, fmt.Errorf("%w: %s in %s", ErrNotFound, id, rev)
+	}
+	return t, nil
+}
+
+// Diff returns the fields of a task that differ between two revisions. A
+// task that does not exist in one of the revisions is compared as empty.
+func (p *Project) Diff(id uuid.UUID, from, to string) ([]task.FieldChange, error) {
+	versions := make([]*task.Task, 2)
+	for i, rev := range []string{from, to} {
+		r, err := p.repo.Resolve(rev)
+		if err != nil {
+			return nil, err
+		}
+
+		tasks, err := p.tasksAt(r.Hash)
+		if err != nil {
+			return nil, err
+		}
+		versions[i] = find(tasks, id)
+	}
+
+	return task.Diff(versions[0], versions[1]), nil
+}
+
+// version returns the task with the given id as it was in the given revision,
+// nil if it did not exist. Only its own task file is read.
+func (p *Project) version(cache map[plumbing.Hash]*task.Task, hash plumbing.Hash, id uuid.UUID) (*task.Task, error) {
+	if t, ok := cache[hash]; ok {
+		return t, nil
+	}
+
+	tasks, err := p.readTasks(hash, func(name string) bool {
+		return backlog.IsFileOf(name, id)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	t := find(tasks, id)
+	cache[hash] = t
+	return t, nil
+}
+
+// tasksAt reads the tasks as they were stored in the given revision, in the
+// task folder or, in older revisions, in a single task file.
+func (p *Project) tasksAt(hash plumbing.Hash) ([]*task.Task, error) {
+	return p.readTasks(hash, func(string) bool { return true })
+}
+
+// readTasks reads the tasks in the files of the task folder whose name matches
+// match or, in older revisions, all tasks in the single task file.
+func (p *Project) readTasks(hash plumbing.Hash, match func(name string) bool) ([]*task.Task, error) {
+	files, err := p.repo.ReadDirFunc(hash, config.TasksFolder, match)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) > 0 {
+		entries, err := backlog.ReadFolder(files, codecs...)
+		if err != nil {
+			return nil, fmt.Errorf("revision %s: %w", hash, err)
+		}
+
+		tasks := make([]*task.Task, 0, len(entries))
+		for _, e := range entries {
+			tasks = append(tasks, e.Task)
+		}
+		return tasks, nil
+	}
+
+	for _, codec := range codecs {
+		content, ok, err := p.repo.ReadFile(hash, tasksFile+codec.Ext())
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+
+		tasks, err := codec.Decode(bytes.NewReader(content))
+		if err != nil {
+			return nil, fmt.Errorf("revision %s: %w", hash, err)
+		}
+		return tasks, nil
+	}
+
+	return nil, nil
+}
+
+func find(tasks []*task.Task, id uuid.UUID) *task.Task {
+	if i := indexOf(tasks, id); i >= 0 {
+		return tasks[i]
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+package project
+
+import (
+	"errors"
+	"fmt"
+	"github.com/tedla-brandsema/tribble/backlog"
+	"github.com/tedla-brandsema/tribble/config"
+	"github.com/tedla-brandsema/tribble/internal/fio"
+	"github.com/tedla-brandsema/tribble/repo"
+	"github.com/tedla-brandsema/tribble/task"
+	"log/slog"
+	"path/filepath"
+	"slices"
+)
+
+var ErrVerification = errors.New("migrated tasks do not match the original tasks")
+
+// Migrate converts the stored tasks to the target serialize mode.
+//
+// The tasks are written next to the current file, read back and compared to
+// the original before tribble.cfg is switched to the new mode. The original
+// file is only removed once the config has been updated, so a failed migration
+// leaves the project exactly as it was.
+func (p *Project) Migrate(target config.SerializeMode) error {
+	return p.withLock(fio.ExclusiveLock, func() error {
+		err := p.reloadMode()
+		if err != nil {
+			return err
+		}
+		if target == p.cfg.SerializeMode {
+			return nil
+		}
+		return p.migrate(target)
+	})
+}
+
+// reloadMode reads the serialize mode from tribble.cfg again, as another
+// tribble may have migrated the tasks since the config was loaded, and
+// switches the task folder to it. It must be called with the exclusive task
+// lock held.
+func (p *Project) reloadMode() error {
+	loaded, err := config.Load(p.cfg.Root(), append(slices.Clip(p.opts), config.WithFS(p.cfg.FS()))...)
+	if err != nil {
+		return err
+	}
+	if loaded.SerializeMode == p.cfg.SerializeMode {
+		return nil
+	}
+	codec, err := Codec(loaded.SerializeMode)
+	if err != nil {
+		return err
+	}
+
+	cfg := *p.cfg
+	cfg.SerializeMode = loaded.SerializeMode
+	p.cfg = &cfg
+	p.tasks = tasksFolder(&cfg, codec)
+	return nil
+}
+
+func (p *Project) migrate(target config.SerializeMode) error {
+	codec, err := Codec(target)
+	if err != nil {
+		return err
+	}
+
+	tasks, err := p.tasks.Load()
+	if err != nil {
+		return fmt.Errorf("unable to read tasks: %w", err)
+	}
+
+	migrated := tasksFolder(p.cfg, codec)
+	err = migrated.Save(tasks)
+	if err != nil {
+		discard(migrated)
+		return fmt.Errorf("unable to write tasks: %w", err)
+	}
+
+	err = verify(migrated, tasks)
+	if err != nil {
+		discard(migrated)
+		return err
+	}
+
+	cfg := *p.cfg
+	cfg.SerializeMode = target
+	err = cfg.Save()
+	if err != nil {
+		discard(migrated)
+		return fmt.Errorf("unable to update config: %w", err)
+	}
+
+	original := p.tasks
+	p.cfg = &cfg
+	p.tasks = migrated
+
+	if err = original.Remove(); err != nil {
+		slog.Warn("unable to remove migrated task files",
+			slog.String("path", original.Path()),
+			slog.String("ext", original.Codec().Ext()),
+			slog.Any("error", err),
+		)
+	}
+
+	return p.commit(repo.Change{Action: repo.ActionMigrate, Title: target.String()}, filepath.Base(p.cfg.Path()))
+}
+
+// verify reads the tasks back from store and compares them to expected.
+func verify(store interface{ Load() ([]*task.Task, error) }, expected []*task.Task) error {
+	actual, err := store.Load()
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrVerification, err)
+	}
+
+	if len(actual) != len(expected) {
+		return fmt.Errorf("%w: expected %d tasks, found %d", ErrVerification, len(expected), len(actual))
+	}
+	for i := range expected {
+		if !expected[i].Equal(actual[i]) {
+			return fmt.Errorf("%w: task %s differs", ErrVerification, expected[i].ID)
+		}
+	}
+	return nil
+}
+
+func discard(folder *backlog.Folder) {
+	if err := folder.Remove(); err != nil {
+		slog.Warn("unable to remove incomplete migration",
+			slog.String("path", folder.Path()),
+			slog.String("ext", folder.Codec().Ext()),
+			slog.Any("error", err),
+		)
+	}
+}
+
+// Recover repairs damaged binary task files, see fio.RecoverFrames. It
+// reports what was found in every task file, by file name. A task file holds a
+// single task, so a file left without an intact task is removed, and its task
+// is dropped from the backlog order. The repair is rendered to the markdown
+// backlog and committed as a change of its own.
+func (p *Project) Recover(mode fio.RecoveryMode) (map[string]*fio.ScanReport, error) {
+	if p.Config().SerializeMode != config.SerializeBinary {
+		return nil, fmt.Errorf("recover only applies to the %s serialize mode", config.SerializeBinary)
+	}
+
+	reports := make(map[string]*fio.ScanReport)
+	err := p.withLock(fio.ExclusiveLock, func() error {
+		// The files are listed rather than loaded, damaged files would not
+		// decode.
+		files, err := p.tasks.Files()
+		if err != nil {
+			return err
+		}
+
+		var repaired bool
+		for _, file := range files {
+			path := filepath.Join(p.tasks.Path(), file)
+			report, err := fio.RecoverFrames(p.cfg.FS(), path, mode)
+			if err != nil {
+				return fmt.Errorf("%s: %w", file, err)
+			}
+			reports[file] = report
+			if report.Clean() && len(report.Records) > 0 {
+				continue
+			}
+			repaired = true
+			if len(report.Records) > 0 {
+				continue
+			}
+
+			slog.Warn("removing task file without an intact task",
+				slog.String("path", path),
+			)
+			err = p.cfg.FS().Remove(path)
+			if err != nil {
+				return fmt.Errorf("%s: %w", file, err)
+			}
+		}
+		if !repaired {
+			return nil
+		}
+
+		// Saving the tasks that are left writes the order file and the
+		// backlog without the removed tasks.
+		tasks, err := p.tasks.Load()
+		if err != nil {
+			return err
+		}
+		err = p.save(tasks)
+		if err != nil {
+			return err
+		}
+		return p.commit(repo.Change{Action: repo.ActionRecover, Title: config.TasksFolder})
+	})
+
+	return reports, err
+}
@@ -0,0 +1,462 @@
+package project
+
+import (
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/tedla-brandsema/tribble/backlog"
+	"github.com/tedla-brandsema/tribble/config"
+	"github.com/tedla-brandsema/tribble/internal/fio"
+	"github.com/tedla-brandsema/tribble/repo"
+	"github.com/tedla-brandsema/tribble/task"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	// tasksFile is the file all tasks were stored in before every task had a
+	// file of its own in config.TasksFolder.
+	tasksFile = "tasks"
+	lockFile  = "tasks.lock"
+)
+
+var (
+	ErrNotFound = errors.New("task not found")
+	// ErrAmbiguous means a reference to a task matches several tasks.
+	ErrAmbiguous = errors.New("task reference is ambiguous")
+)
+
+// Project ties a tribble configuration to the task storage it selects, and
+// records every change to the tasks in the repository in the tribble folder.
+type Project struct {
+	// mux guards the fields below, which are replaced when the config
+	// changes. It is held along with the task lock.
+	mux     sync.RWMutex
+	cfg     *config.Config
+	tasks   *backlog.Folder
+	backlog *backlog.Store
+	repo    *repo.Repository
+	// opts are the options the config was loaded with, to load it again.
+	opts []config.Option
+}
+
+// Open opens the project in root, creating its config if there is none yet.
+// The options are passed to config.Load, to override settings for example.
+func Open(root string, opts ...config.Option) (*Project, error) {
+	cfg, err := config.Load(root, append([]config.Option{config.Create()}, opts...)...)
+	if err != nil {
+		return nil, err
+	}
+	p, err := New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	p.opts = opts
+	return p, nil
+}
+
+// New returns the project of cfg. The project keeps its files on the file
+// system of cfg; a project kept in memory, see config.InMemory, keeps its
+// repository in memory as well.
+func New(cfg *config.Config) (*Project, error) {
+	codec, err := Codec(cfg.SerializeMode)
+	if err != nil {
+		return nil, err
+	}
+
+	var opts []repo.Option
+	if m, ok := cfg.FS().(*fio.Memory); ok {
+		worktree, err := m.Filesystem().Chroot(cfg.TribblePath())
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, repo.InMemoryFS(worktree))
+	}
+
+	r, err := repo.Open(cfg.TribblePath(), repo.Author{
+		Name:  cfg.Author.Name,
+		Email: cfg.Author.Email,
+	}, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open repository: %w", err)
+	}
+
+	err = r.SetRemotes(repoRemotes(cfg))
+	if err != nil {
+		return nil, fmt.Errorf("unable to configure remotes: %w", err)
+	}
+
+	cfg.Workspace, err = r.Workspace()
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Project{
+		cfg:     cfg,
+		tasks:   tasksFolder(cfg, codec),
+		backlog: backlog.NewStore(cfg.FS(), cfg.BacklogFile(), backlog.Markdown),
+		repo:    r,
+	}
+
+	err = p.upgrade()
+	if err != nil {
+		return nil, fmt.Errorf("unable to move tasks to %s: %w", p.tasks.Path(), err)
+	}
+	return p, nil
+}
+
+// Codec returns the codec that implements the given serialize mode.
+func Codec(mode config.SerializeMode) (backlog.Codec, error) {
+	switch mode {
+	case config.SerializeMarkdown:
+		return backlog.Markdown, nil
+	case config.SerializeJSON:
+		return backlog.JSON, nil
+	case config.SerializeBinary:
+		return backlog.Binary, nil
+	default:
+		return nil, fmt.Errorf("unknown serialize mode: %d", int(mode))
+	}
+}
+
+func repoRemotes(cfg *config.Config) []repo.Remote {
+	remotes := make([]repo.Remote, 0, len(cfg.Remotes))
+	for _, remote := range cfg.Remotes {
+		remotes = append(remotes, repo.Remote{Name: remote.Name, URL: remote.URL})
+	}
+	return remotes
+}
+
+func tasksFolder(cfg *config.Config, codec backlog.Codec) *backlog.Folder {
+	return backlog.NewFolder(cfg.FS(), cfg.TasksPath(), codec, cfg.TaskPath)
+}
+
+// upgrade moves the tasks of a project that keeps all tasks in a single file
+// to a file per task.
+func (p *Project) upgrade() error {
+	fsys := p.cfg.FS()
+	codec := p.tasks.Codec()
+	legacy := backlog.NewStore(fsys, filepath.Join(p.cfg.TribblePath(), tasksFile+codec.Ext()), codec)
+	if !fsys.FileExists(legacy.Path()) {
+		return nil
+	}
+
+	return p.withLock(fio.ExclusiveLock, func() error {
+		if !fsys.FileExists(legacy.Path()) {
+			return nil
+		}
+
+		tasks, err := legacy.Load()
+		if err != nil {
+			return err
+		}
+		err = p.tasks.Save(tasks)
+		if err != nil {
+			return err
+		}
+		err = verify(p.tasks, tasks)
+		if err != nil {
+			return err
+		}
+
+		err = fsys.Remove(legacy.Path())
+		if err != nil {
+			return err
+		}
+		return p.commit(repo.Change{Action: repo.ActionMigrate, Title: config.TasksFolder}, filepath.Base(legacy.Path()))
+	})
+}
+
+func (p *Project) Config() *config.Config {
+	p.mux.RLock()
+	defer p.mux.RUnlock()
+
+	return p.cfg
+}
+
+// Tasks returns the tasks in the order in which they were saved.
+func (p *Project) Tasks() ([]*task.Task, error) {
+	var tasks []*task.Task
+
+	err := p.withLock(fio.SharedLock, func() error {
+		var err error
+		tasks, err = p.tasks.Load()
+		return err
+	})
+
+	return tasks, err
+}
+
+// Entries returns the tasks in backlog order along with the files they are
+// stored in, see Find.
+func (p *Project) Entries() ([]backlog.Entry, error) {
+	var entries []backlog.Entry
+
+	err := p.withLock(fio.SharedLock, func() error {
+		var err error
+		entries, err = p.tasks.Entries()
+		return err
+	})
+
+	return entries, err
+}
+
+// Task returns the task with the given id.
+func (p *Project) Task(id uuid.UUID) (*task.Task, error) {
+	tasks, err := p.Tasks()
+	if err != nil {
+		return nil, err
+	}
+
+	i := indexOf(tasks, id)
+	if i < 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	return tasks[i], nil
+}
+
+// Find returns the task ref refers to: its id, the name of its file with or
+// without extension, the slug of its title or the start of its id, at least
+// four hex digits. It returns ErrAmbiguous if ref matches several tasks.
+func (p *Project) Find(ref string) (*task.Task, error) {
+	if id, err := uuid.Parse(ref); err == nil {
+		return p.Task(id)
+	}
+
+	entries, err := p.Entries()
+	if err != nil {
+		return nil, err
+	}
+
+	ref = strings.ToLower(ref)
+	matchers := []func(e backlog.Entry) bool{
+		func(e backlog.Entry) bool {
+			return e.File == ref || backlog.Stem(e.File) == ref
+		},
+		func(e backlog.Entry) bool {
+			return backlog.Slug(e.File) == ref
+		},
+		func(e backlog.Entry) bool {
+			return len(ref) >= 4 && strings.HasPrefix(strings.ReplaceAll(e.Task.ID.String(), "-", ""), ref)
+		},
+	}
+	for _, match := range matchers {
+		var found []backlog.Entry
+		for _, e := range entries {
+			if match(e) {
+				found = append(found, e)
+			}
+		}
+
+		switch len(found) {
+		case 0:
+			continue
+		case 1:
+			return found[0].Task, nil
+		default:
+			files := make([]string, 0, len(found))
+			for _, e := range found {
+				files = append(files, backlog.Stem(e.File))
+			}
+			return nil, fmt.Errorf("%w: %q matches %s", ErrAmbiguous, ref, strings.Join(files, ", "))
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrNotFound, ref)
+}
+
+// Create adds a new task to the end of the backlog.
+func (p *Project) Create(title, description string) (*task.Task, error) {
+	t, err := task.New(title, description)
+	if err != nil {
+		return nil, err
+	}
+
+	err = p.Add(t)
+	if err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// Add adds t, a task made with task.New, to the end of the backlog.
+func (p *Project) Add(t *task.Task) error {
+	err := t.Validate()
+	if err != nil {
+		return err
+	}
+
+	return p.mutate(&repo.Change{Action: repo.ActionCreate, TaskID: t.ID, Title: t.Title}, func(tasks []*task.Task) ([]*task.Task, error) {
+		if indexOf(tasks, t.ID) >= 0 {
+			return nil, fmt.Errorf("task %s already exists", t.ID)
+		}
+		return append(tasks, t), nil
+	})
+}
+
+// Update replaces the stored task that has the same id as t.
+func (p *Project) Update(t *task.Task) error {
+	err := t.Validate()
+	if err != nil {
+		return err
+	}
+
+	return p.mutate(&repo.Change{Action: repo.ActionUpdate, TaskID: t.ID, Title: t.Title}, func(tasks []*task.Task) ([]*task.Task, error) {
+		i := indexOf(tasks, t.ID)
+		if i < 0 {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, t.ID)
+		}
+		tasks[i] = t
+		return tasks, nil
+	})
+}
+
+// Edit applies fn to the task with the given id and saves it. The task is read
+// and saved under one lock, so no change made meanwhile is lost. Nothing is
+// saved if fn returns an error.
+func (p *Project) Edit(id uuid.UUID, fn func(t *task.Task) error) (*task.Task, error) {
+	change := &repo.Change{Action: repo.ActionUpdate, TaskID: id}
+
+	var t *task.Task
+	err := p.mutate(change, func(tasks []*task.Task) ([]*task.Task, error) {
+		i := indexOf(tasks, id)
+		if i < 0 {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+		}
+		t = tasks[i]
+		if err := fn(t); err != nil {
+			return nil, err
+		}
+		change.Title = t.Title
+		return tasks, t.Validate()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// SetStatus sets the status of the task with the given id. The task is read
+// and saved under one lock, so changes to its other fields made meanwhile are
+// kept.
+func (p *Project) SetStatus(id uuid.UUID, status task.Status) (*task.Task, error) {
+	return p.Edit(id, func(t *task.Task) error {
+		return t.SetStatus(status)
+	})
+}
+
+// Delete removes the task with the given id.
+func (p *Project) Delete(id uuid.UUID) error {
+	change := &repo.Change{Action: repo.ActionDelete, TaskID: id}
+
+	return p.mutate(change, func(tasks []*task.Task) ([]*task.Task, error) {
+		i := indexOf(tasks, id)
+		if i < 0 {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+		}
+		change.Title = tasks[i].Title
+		return append(tasks[:i], tasks[i+1:]...), nil
+	})
+}
+
+// Save replaces all tasks, stores them in the configured format and renders
+// them to the markdown backlog.
+func (p *Project) Save(tasks []*task.Task) error {
+	return p.write(func() error {
+		err := p.save(tasks)
+		if err != nil {
+			return err
+		}
+		return p.commit(repo.Change{Action: repo.ActionSave, Title: "backlog"})
+	})
+}
+
+// mutate applies fn to the stored tasks, saves the result and commits it as
+// the given change. fn may fill in details of the change.
+func (p *Project) mutate(change *repo.Change, fn func([]*task.Task) ([]*task.Task, error)) error {
+	return p.write(func() error {
+		tasks, err := p.tasks.Load()
+		if err != nil {
+			return err
+		}
+
+		tasks, err = fn(tasks)
+		if err != nil {
+			return err
+		}
+
+		err = p.save(tasks)
+		if err != nil {
+			return err
+		}
+		return p.commit(*change)
+	})
+}
+
+func (p *Project) save(tasks []*task.Task) error {
+	err := p.tasks.Save(tasks)
+	if err != nil {
+		return err
+	}
+
+	return p.render(tasks)
+}
+
+// commit records the change to the task files and any of the given paths, all
+// relative to the tribble folder. A task file that was renamed is recorded as
+// a rename. Files left in the task folder by interrupted writes are removed
+// rather than committed.
+func (p *Project) commit(change repo.Change, paths ...string) error {
+	paths = append(paths, config.TasksFolder)
+
+	err := p.tasks.RemoveTemp()
+	if err != nil {
+		return fmt.Errorf("unable to commit %s: %w", change.Action, err)
+	}
+	_, err = p.repo.Commit(change, paths...)
+	if err != nil {
+		return fmt.Errorf("unable to commit %s: %w", change.Action, err)
+	}
+	return nil
+}
+
+func indexOf(tasks []*task.Task, id uuid.UUID) int {
+	for i, t := range tasks {
+		if t.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// withLock runs fn while holding the task lock, which guards the task file and
+// the markdown backlog against concurrent tribble processes.
+func (p *Project) withLock(mode fio.LockMode, fn func() error) error {
+	if mode == fio.ExclusiveLock {
+		p.mux.Lock()
+		defer p.mux.Unlock()
+	} else {
+		p.mux.RLock()
+		defer p.mux.RUnlock()
+	}
+	path := filepath.Join(p.cfg.TribblePath(), lockFile)
+
+	l, err := p.cfg.FS().AcquireLock(path, mode, config.LockTimeout)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := l.Release(); err != nil {
+			slog.Error("unable to release lock",
+				slog.String("path", path),
+				slog.Any("error", err),
+			)
+		}
+	}()
+
+	return fn()
+}
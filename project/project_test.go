@@ -0,0 +1,996 @@
+package project
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/tedla-brandsema/tribble/backlog"
+	"github.com/tedla-brandsema/tribble/config"
+	"github.com/tedla-brandsema/tribble/internal/fio"
+	"github.com/tedla-brandsema/tribble/internal/testenv"
+	"github.com/tedla-brandsema/tribble/repo"
+	"github.com/tedla-brandsema/tribble/task"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testProject returns a project in a new folder that is kept in memory.
+func testProject(t *testing.T) *Project {
+	t.Helper()
+
+	cfg, err := config.Load(t.TempDir(), config.Create(), config.InMemory())
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	p, err := New(cfg)
+	if err != nil {
+		t.Fatalf("Failed to open project: %v", err)
+	}
+	return p
+}
+
+// reopen opens the project of p again from its files. The repository of the
+// reopened project starts out empty, as repositories in memory are not shared.
+func reopen(p *Project) (*Project, error) {
+	cfg, err := config.Load(p.Config().Root(), config.WithFS(p.Config().FS()))
+	if err != nil {
+		return nil, err
+	}
+	return New(cfg)
+}
+
+func TestProject(t *testing.T) {
+	testenv.Isolate(t)
+
+	p := testProject(t)
+
+	first, err := p.Create("First", "Description")
+	if err != nil {
+		t.Fatalf("Failed to create task: %v", err)
+	}
+	second, err := p.Create("Second", "")
+	if err != nil {
+		t.Fatalf("Failed to create task: %v", err)
+	}
+
+	t.Run("Update", func(t *testing.T) {
+		if err := first.SetStatus(task.StatusDone); err != nil {
+			t.Fatalf("Failed to set status: %v", err)
+		}
+		if err := p.Update(first); err != nil {
+			t.Fatalf("Failed to update task: %v", err)
+		}
+
+		got, err := p.Task(first.ID)
+		if err != nil {
+			t.Fatalf("Failed to read task: %v", err)
+		}
+		if !got.Equal(first) {
+			t.Fatalf("Expected %+v, got %+v", first, got)
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		if err := p.Delete(second.ID); err != nil {
+			t.Fatalf("Failed to delete task: %v", err)
+		}
+		if _, err := p.Task(second.ID); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("Expected error %v, got %v", ErrNotFound, err)
+		}
+	})
+
+	t.Run("Backlog", func(t *testing.T) {
+		content, err := p.Config().FS().ReadFile(p.Config().BacklogFile())
+		if err != nil {
+			t.Fatalf("Failed to read backlog: %v", err)
+		}
+		if !strings.Contains(string(content), "- [x] First") || strings.Contains(string(content), "Second") {
+			t.Fatalf("Expected only the first task in the backlog, got:\n%s", content)
+		}
+	})
+
+	t.Run("History", func(t *testing.T) {
+		// A new title moves the task to another file, its history follows it.
+		if err := first.SetTitle("Renamed"); err != nil {
+			t.Fatalf("Failed to set title: %v", err)
+		}
+		if err := p.Update(first); err != nil {
+			t.Fatalf("Failed to update task: %v", err)
+		}
+
+		history, err := p.History(first.ID)
+		if err != nil {
+			t.Fatalf("Failed to read history: %v", err)
+		}
+		if len(history) != 3 || history[1].Action != repo.ActionUpdate || history[2].Action != repo.ActionCreate {
+			t.Fatalf("Expected two updates after a create, got %+v", history)
+		}
+		if got := history[0].Changes; len(got) == 0 || got[0].Field != "Title" || got[0].New != "Renamed" {
+			t.Fatalf("Expected the new title, got %+v", got)
+		}
+	})
+
+	t.Run("Migrate", func(t *testing.T) {
+		if err := p.Migrate(config.SerializeJSON); err != nil {
+			t.Fatalf("Failed to migrate: %v", err)
+		}
+
+		reopened, err := reopen(p)
+		if err != nil {
+			t.Fatalf("Failed to reopen project: %v", err)
+		}
+		tasks, err := reopened.Tasks()
+		if err != nil {
+			t.Fatalf("Failed to read tasks: %v", err)
+		}
+		if len(tasks) != 1 || !tasks[0].Equal(first) {
+			t.Fatalf("Expected the first task, got %v", tasks)
+		}
+	})
+
+	t.Run("Nothing on disk", func(t *testing.T) {
+		entries, err := os.ReadDir(p.Config().Root())
+		if err != nil {
+			t.Fatalf("Failed to read project folder: %v", err)
+		}
+		if len(entries) != 0 {
+			t.Fatalf("Expected an empty project folder, got %v", entries)
+		}
+	})
+}
+
+func TestOpen(t *testing.T) {
+	testenv.Isolate(t)
+
+	p, err := Open(t.TempDir(), config.InMemory(), config.Set(config.KeyAuthorName, "Ada"))
+	if err != nil {
+		t.Fatalf("Failed to open project: %v", err)
+	}
+	if name := p.Config().Author.Name; name != "Ada" {
+		t.Fatalf("Expected author Ada, got %q", name)
+	}
+	if source := p.Config().Origin(config.KeyAuthorName).Source; source != config.SourceOverride {
+		t.Fatalf("Expected the author from the override, got %s", source)
+	}
+
+	t.Run("Watch", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		w := p.Watch(ctx, time.Hour)
+
+		changed := *p.Config()
+		changed.BacklogPath = "docs.md"
+		if err := changed.Save(); err != nil {
+			t.Fatalf("Failed to save config: %v", err)
+		}
+		if !w.Check() {
+			t.Fatalf("Expected a change")
+		}
+		if cfg := p.Config(); cfg.BacklogPath != "docs.md" || cfg.Author.Name != "Ada" {
+			t.Fatalf("Expected the new backlog path and the override, got %+v", cfg)
+		}
+	})
+}
+
+func TestWorkspaceConflict(t *testing.T) {
+	testenv.Isolate(t)
+
+	p := testProject(t)
+
+	tk, err := p.Create("Task", "")
+	if err != nil {
+		t.Fatalf("Failed to create task: %v", err)
+	}
+	main := p.Config().Workspace
+
+	if _, err = p.CreateWorkspace("experiment", ""); err != nil {
+		t.Fatalf("Failed to create workspace: %v", err)
+	}
+	if err = p.SwitchWorkspace("experiment"); err != nil {
+		t.Fatalf("Failed to switch workspace: %v", err)
+	}
+	if p.Config().Workspace != "experiment" {
+		t.Fatalf("Expected workspace experiment in the config, got %q", p.Config().Workspace)
+	}
+
+	theirs := *tk
+	if err = theirs.Update("Theirs", "Their description"); err != nil {
+		t.Fatalf("Failed to update task: %v", err)
+	}
+	if err = p.Update(&theirs); err != nil {
+		t.Fatalf("Failed to update task: %v", err)
+	}
+
+	if err = p.SwitchWorkspace(main); err != nil {
+		t.Fatalf("Failed to switch workspace: %v", err)
+	}
+	ours := *tk
+	if err = ours.SetTitle("Ours"); err != nil {
+		t.Fatalf("Failed to update task: %v", err)
+	}
+	if err = p.Update(&ours); err != nil {
+		t.Fatalf("Failed to update task: %v", err)
+	}
+
+	result, err := p.MergeWorkspace("experiment")
+	if err != nil {
+		t.Fatalf("Failed to merge workspace: %v", err)
+	}
+	if result.Status != repo.Merged {
+		t.Fatalf("Expected status %d, got %d", repo.Merged, result.Status)
+	}
+
+	merged, err := p.Task(tk.ID)
+	if err != nil {
+		t.Fatalf("Failed to read task: %v", err)
+	}
+	if merged.Title != "Ours" || merged.Description != "Their description" {
+		t.Fatalf("Expected our title and their description, got %+v", merged)
+	}
+
+	conflicts, err := p.Conflicts()
+	if err != nil {
+		t.Fatalf("Failed to read conflicts: %v", err)
+	}
+	expected := task.Conflict{TaskID: tk.ID, Field: "Title", Base: "Task", Ours: "Ours", Theirs: "Theirs"}
+	if len(conflicts) != 1 || conflicts[0] != expected {
+		t.Fatalf("Expected conflict %+v, got %+v", expected, conflicts)
+	}
+
+	if err = p.Resolve(tk.ID, "Title", conflicts[0].Theirs); err != nil {
+		t.Fatalf("Failed to resolve conflict: %v", err)
+	}
+	if err = p.Resolve(tk.ID, "Title", "Again"); !errors.Is(err, ErrNoConflict) {
+		t.Fatalf("Expected error %v, got %v", ErrNoConflict, err)
+	}
+
+	resolved, err := p.Task(tk.ID)
+	if err != nil {
+		t.Fatalf("Failed to read task: %v", err)
+	}
+	if resolved.Title != "Theirs" {
+		t.Fatalf("Expected title %q, got %q", "Theirs", resolved.Title)
+	}
+	if conflicts, _ = p.Conflicts(); len(conflicts) != 0 {
+		t.Fatalf("Expected no conflicts, got %+v", conflicts)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	testenv.Isolate(t)
+
+	p := testProject(t)
+	if _, err := p.Create("Task", ""); err != nil {
+		t.Fatalf("Failed to create task: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	w := p.Watch(ctx, time.Hour)
+
+	changed := *p.Config()
+	changed.BacklogPath = "docs.md"
+	changed.Author = config.Author{Name: "Other", Email: "other@example.com"}
+	if err := changed.Save(); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+	if !w.Check() {
+		t.Fatalf("Expected a change")
+	}
+
+	if p.Config().BacklogPath != "docs.md" {
+		t.Fatalf("Expected backlog path docs.md, got %q", p.Config().BacklogPath)
+	}
+	content, err := p.Config().FS().ReadFile(filepath.Join(p.Config().Root(), "docs.md"))
+	if err != nil {
+		t.Fatalf("Failed to read backlog: %v", err)
+	}
+	if !strings.Contains(string(content), "Task") {
+		t.Fatalf("Expected the task in the backlog, got:\n%s", content)
+	}
+
+	tk, err := p.Create("Second", "")
+	if err != nil {
+		t.Fatalf("Failed to create task: %v", err)
+	}
+	history, err := p.History(tk.ID)
+	if err != nil {
+		t.Fatalf("Failed to read history: %v", err)
+	}
+	if len(history) != 1 || history[0].Author.Name != "Other" {
+		t.Fatalf("Expected a commit by the new author, got %+v", history)
+	}
+}
+
+func taskFiles(t *testing.T, p *Project) []string {
+	t.Helper()
+
+	dir, err := p.Config().FS().ReadDir(p.Config().TasksPath())
+	if err != nil {
+		t.Fatalf("Failed to read task folder: %v", err)
+	}
+	var files []string
+	for _, e := range dir {
+		if e.Name() != backlog.OrderFile {
+			files = append(files, e.Name())
+		}
+	}
+	return files
+}
+
+func TestTaskFiles(t *testing.T) {
+	testenv.Isolate(t)
+
+	p := testProject(t)
+
+	login, err := p.Create("Fix the login", "")
+	if err != nil {
+		t.Fatalf("Failed to create task: %v", err)
+	}
+	if _, err = p.Create("Fix the login", "Again"); err != nil {
+		t.Fatalf("Failed to create task: %v", err)
+	}
+	docs, err := p.Create("Write docs", "")
+	if err != nil {
+		t.Fatalf("Failed to create task: %v", err)
+	}
+	short := strings.ReplaceAll(docs.ID.String(), "-", "")[:8]
+
+	t.Run("Files", func(t *testing.T) {
+		files := taskFiles(t, p)
+		if len(files) != 3 || !slices.Contains(files, "write-docs-"+short+".md") {
+			t.Fatalf("Expected a file per task, got %v", files)
+		}
+	})
+
+	t.Run("Find", func(t *testing.T) {
+		tests := []struct {
+			ref           string
+			expected      *task.Task
+			expectedError error
+		}{
+			{ref: docs.ID.String(), expected: docs},
+			{ref: "write-docs", expected: docs},
+			{ref: "write-docs-" + short, expected: docs},
+			{ref: "write-docs-" + short + ".md", expected: docs},
+			{ref: strings.ToUpper(short[:6]), expected: docs},
+			{ref: "fix-the-login", expectedError: ErrAmbiguous},
+			{ref: "missing", expectedError: ErrNotFound},
+		}
+
+		for _, test := range tests {
+			got, err := p.Find(test.ref)
+			if !errors.Is(err, test.expectedError) {
+				t.Fatalf("%s: expected error %v, got %v", test.ref, test.expectedError, err)
+			}
+			if test.expected != nil && (got == nil || got.ID != test.expected.ID) {
+				t.Fatalf("%s: expected task %s, got %v", test.ref, test.expected.ID, got)
+			}
+		}
+	})
+
+	t.Run("Rename", func(t *testing.T) {
+		if err := docs.SetTitle("Write the docs"); err != nil {
+			t.Fatalf("Failed to set title: %v", err)
+		}
+		if err := p.Update(docs); err != nil {
+			t.Fatalf("Failed to update task: %v", err)
+		}
+
+		files := taskFiles(t, p)
+		if len(files) != 3 || !slices.Contains(files, "write-the-docs-"+short+".md") {
+			t.Fatalf("Expected the file to be renamed, got %v", files)
+		}
+		history, err := p.History(docs.ID)
+		if err != nil {
+			t.Fatalf("Failed to read history: %v", err)
+		}
+		if len(history) != 2 {
+			t.Fatalf("Expected the history to follow the rename, got %+v", history)
+		}
+	})
+
+	t.Run("Interrupted write", func(t *testing.T) {
+		// A write that was interrupted leaves its temporary file behind.
+		leftover := "." + taskFiles(t, p)[0] + ".tmp-123"
+		path := filepath.Join(p.Config().TasksPath(), leftover)
+		if err := p.Config().FS().OverwriteFile(path, []byte("# Half")); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+
+		docs.SetDescription("Interrupted")
+		if err := p.Update(docs); err != nil {
+			t.Fatalf("Failed to update task: %v", err)
+		}
+		if p.Config().FS().FileExists(path) {
+			t.Fatalf("Expected %s to be removed", leftover)
+		}
+		rev, err := p.Revision("HEAD")
+		if err != nil {
+			t.Fatalf("Failed to resolve HEAD: %v", err)
+		}
+		if _, ok, err := p.repo.ReadFile(rev.Hash, config.TasksFolder+"/"+leftover); err != nil || ok {
+			t.Fatalf("Expected %s not to be committed, got %v", leftover, err)
+		}
+	})
+
+	t.Run("Merge a renamed task", func(t *testing.T) {
+		main := p.Config().Workspace
+		if _, err := p.CreateWorkspace("rename", ""); err != nil {
+			t.Fatalf("Failed to create workspace: %v", err)
+		}
+		if err := p.SwitchWorkspace("rename"); err != nil {
+			t.Fatalf("Failed to switch workspace: %v", err)
+		}
+		renamed := *login
+		if err := renamed.SetTitle("Fix the sign in"); err != nil {
+			t.Fatalf("Failed to set title: %v", err)
+		}
+		if err := p.Update(&renamed); err != nil {
+			t.Fatalf("Failed to update task: %v", err)
+		}
+
+		if err := p.SwitchWorkspace(main); err != nil {
+			t.Fatalf("Failed to switch workspace: %v", err)
+		}
+		described := *login
+		described.SetDescription("Users are logged out")
+		if err := p.Update(&described); err != nil {
+			t.Fatalf("Failed to update task: %v", err)
+		}
+
+		if _, err := p.MergeWorkspace("rename"); err != nil {
+			t.Fatalf("Failed to merge workspace: %v", err)
+		}
+		merged, err := p.Task(login.ID)
+		if err != nil {
+			t.Fatalf("Failed to read task: %v", err)
+		}
+		if merged.Title != "Fix the sign in" || merged.Description != "Users are logged out" {
+			t.Fatalf("Expected both changes, got %+v", merged)
+		}
+		if files := taskFiles(t, p); len(files) != 3 {
+			t.Fatalf("Expected one file per task, got %v", files)
+		}
+	})
+}
+
+func TestUpgrade(t *testing.T) {
+	testenv.Isolate(t)
+
+	p := testProject(t)
+
+	tk, err := task.New("Stored in one file", "")
+	if err != nil {
+		t.Fatalf("Failed to create task: %v", err)
+	}
+	legacy := backlog.NewStore(p.Config().FS(), filepath.Join(p.Config().TribblePath(), tasksFile+backlog.Markdown.Ext()), backlog.Markdown)
+	if err = legacy.Save([]*task.Task{tk}); err != nil {
+		t.Fatalf("Failed to write task file: %v", err)
+	}
+
+	p, err = reopen(p)
+	if err != nil {
+		t.Fatalf("Failed to open project: %v", err)
+	}
+	tasks, err := p.Tasks()
+	if err != nil {
+		t.Fatalf("Failed to read tasks: %v", err)
+	}
+	if len(tasks) != 1 || !tasks[0].Equal(tk) {
+		t.Fatalf("Expected the task of the task file, got %v", tasks)
+	}
+	if p.Config().FS().FileExists(legacy.Path()) {
+		t.Fatalf("Expected the task file to be removed")
+	}
+	if files := taskFiles(t, p); len(files) != 1 {
+		t.Fatalf("Expected one task file, got %v", files)
+	}
+}
+
+func TestBacklogEdits(t *testing.T) {
+	testenv.Isolate(t)
+
+	p := testProject(t)
+	first, err := p.Create("First", "")
+	if err != nil {
+		t.Fatalf("Failed to create task: %v", err)
+	}
+	if _, err = p.Create("Second", ""); err != nil {
+		t.Fatalf("Failed to create task: %v", err)
+	}
+	path := p.Config().BacklogFile()
+
+	edit := func(t *testing.T, old, new string) {
+		t.Helper()
+
+		content, err := p.Config().FS().ReadFile(path)
+		if err != nil {
+			t.Fatalf("Failed to read backlog: %v", err)
+		}
+		if !strings.Contains(string(content), old) {
+			t.Fatalf("Expected %q in the backlog, got:\n%s", old, content)
+		}
+		content = []byte(strings.Replace(string(content), old, new, 1))
+		if err = p.Config().FS().OverwriteFile(path, content); err != nil {
+			t.Fatalf("Failed to write backlog: %v", err)
+		}
+	}
+
+	t.Run("Import", func(t *testing.T) {
+		edit(t, "- [ ] First", "- [x] First")
+
+		if _, err := p.Create("Third", ""); err != nil {
+			t.Fatalf("Failed to create task: %v", err)
+		}
+
+		got, err := p.Task(first.ID)
+		if err != nil {
+			t.Fatalf("Failed to read task: %v", err)
+		}
+		if got.Status != task.StatusDone {
+			t.Fatalf("Expected the edited status %q, got %q", task.StatusDone, got.Status)
+		}
+		content, err := p.Config().FS().ReadFile(path)
+		if err != nil {
+			t.Fatalf("Failed to read backlog: %v", err)
+		}
+		if !strings.Contains(string(content), "- [x] First") || !strings.Contains(string(content), "Third") {
+			t.Fatalf("Expected the edit and the new task in the backlog, got:\n%s", content)
+		}
+
+		history, err := p.History(first.ID)
+		if err != nil {
+			t.Fatalf("Failed to read history: %v", err)
+		}
+		if len(history) != 2 || !strings.HasPrefix(history[0].Message, string(repo.ActionSave)+": ") {
+			t.Fatalf("Expected the edit to be committed, got %+v", history)
+		}
+	})
+
+	t.Run("Unreadable", func(t *testing.T) {
+		edit(t, "* Status: todo", "* Status: someday")
+
+		_, err := p.Create("Fourth", "")
+		if !errors.Is(err, ErrBacklogEdited) {
+			t.Fatalf("Expected error %v, got %v", ErrBacklogEdited, err)
+		}
+		content, err := p.Config().FS().ReadFile(path)
+		if err != nil {
+			t.Fatalf("Failed to read backlog: %v", err)
+		}
+		if !strings.Contains(string(content), "* Status: someday") {
+			t.Fatalf("Expected the edit to be kept, got:\n%s", content)
+		}
+	})
+}
+
+func TestRestore(t *testing.T) {
+	testenv.Isolate(t)
+
+	p := testProject(t)
+	first, err := p.Create("First", "Before")
+	if err != nil {
+		t.Fatalf("Failed to create task: %v", err)
+	}
+	second, err := p.Create("Second", "")
+	if err != nil {
+		t.Fatalf("Failed to create task: %v", err)
+	}
+	head := func(t *testing.T) repo.Revision {
+		t.Helper()
+
+		rev, err := p.Revision("HEAD")
+		if err != nil {
+			t.Fatalf("Failed to resolve HEAD: %v", err)
+		}
+		return rev
+	}
+	before := head(t)
+
+	// Commit times have a resolution of a second, let the changes below
+	// happen after the revision to restore.
+	time.Sleep(time.Until(before.When.Truncate(time.Second).Add(time.Second)))
+
+	edited := *first
+	edited.SetDescription("After")
+	if err = p.Update(&edited); err != nil {
+		t.Fatalf("Failed to update task: %v", err)
+	}
+	if err = p.Delete(second.ID); err != nil {
+		t.Fatalf("Failed to delete task: %v", err)
+	}
+	if _, err = p.Create("Third", ""); err != nil {
+		t.Fatalf("Failed to create task: %v", err)
+	}
+
+	// restored checks that a restore of rev was committed on top of parent.
+	restored := func(t *testing.T, parent, rev repo.Revision) {
+		t.Helper()
+
+		got := head(t)
+		change, ok := got.Change()
+		if !ok || change.Action != repo.ActionRestore || change.Revision != rev.Hash {
+			t.Fatalf("Expected a restore of %s, got %q", rev.Hash, got.Message)
+		}
+		if len(got.Parents) != 1 || got.Parents[0] != parent.Hash {
+			t.Fatalf("Expected the restore to follow %s, got parents %v", parent.Hash, got.Parents)
+		}
+	}
+
+	t.Run("Task", func(t *testing.T) {
+		parent := head(t)
+
+		got, err := p.RestoreTask(first.ID, before)
+		if err != nil {
+			t.Fatalf("Failed to restore task: %v", err)
+		}
+		if got.Description != "Before" || got.Modified.Before(edited.Modified) {
+			t.Fatalf("Expected the task as it was before, touched, got %+v", got)
+		}
+		stored, err := p.Task(first.ID)
+		if err != nil {
+			t.Fatalf("Failed to read task: %v", err)
+		}
+		if !stored.Equal(got) {
+			t.Fatalf("Expected %+v, got %+v", got, stored)
+		}
+		restored(t, parent, before)
+	})
+
+	t.Run("DeletedTask", func(t *testing.T) {
+		parent := head(t)
+
+		if _, err := p.RestoreTask(second.ID, before); err != nil {
+			t.Fatalf("Failed to restore task: %v", err)
+		}
+		tasks, err := p.Tasks()
+		if err != nil {
+			t.Fatalf("Failed to read tasks: %v", err)
+		}
+		if len(tasks) != 3 || tasks[1].ID != second.ID {
+			t.Fatalf("Expected the task back at its position, got %v", tasks)
+		}
+		restored(t, parent, before)
+	})
+
+	t.Run("Backlog", func(t *testing.T) {
+		edited.SetDescription("Again")
+		if err := p.Update(&edited); err != nil {
+			t.Fatalf("Failed to update task: %v", err)
+		}
+		parent := head(t)
+
+		rev, err := p.RevisionAt(before.When)
+		if err != nil {
+			t.Fatalf("Failed to find revision: %v", err)
+		}
+		if rev.Hash != before.Hash {
+			t.Fatalf("Expected revision %s, got %s", before.Hash, rev.Hash)
+		}
+		if err = p.RestoreBacklog(rev); err != nil {
+			t.Fatalf("Failed to restore backlog: %v", err)
+		}
+
+		tasks, err := p.Tasks()
+		if err != nil {
+			t.Fatalf("Failed to read tasks: %v", err)
+		}
+		if len(tasks) != 2 || tasks[0].ID != first.ID || tasks[1].ID != second.ID {
+			t.Fatalf("Expected the tasks of %s, got %v", rev.Hash, tasks)
+		}
+		if tasks[0].Description != "Before" || tasks[0].Modified.Before(edited.Modified) {
+			t.Fatalf("Expected the task as it was before, touched, got %+v", tasks[0])
+		}
+		restored(t, parent, rev)
+	})
+}
+
+func TestMigrate(t *testing.T) {
+	testenv.Isolate(t)
+
+	p := testProject(t)
+	for _, title := range []string{"First", "Second"} {
+		if _, err := p.Create(title, "Description"); err != nil {
+			t.Fatalf("Failed to create task: %v", err)
+		}
+	}
+	original, err := p.Tasks()
+	if err != nil {
+		t.Fatalf("Failed to read tasks: %v", err)
+	}
+	head := func(t *testing.T) repo.Revision {
+		t.Helper()
+
+		rev, err := p.Revision("HEAD")
+		if err != nil {
+			t.Fatalf("Failed to resolve HEAD: %v", err)
+		}
+		return rev
+	}
+
+	for _, target := range []config.SerializeMode{config.SerializeJSON, config.SerializeBinary, config.SerializeMarkdown} {
+		t.Run(target.String(), func(t *testing.T) {
+			if err := p.Migrate(target); err != nil {
+				t.Fatalf("Failed to migrate: %v", err)
+			}
+
+			reopened, err := reopen(p)
+			if err != nil {
+				t.Fatalf("Failed to open project: %v", err)
+			}
+			if mode := reopened.Config().SerializeMode; mode != target {
+				t.Fatalf("Expected serialize mode %s, got %s", target, mode)
+			}
+			tasks, err := reopened.Tasks()
+			if err != nil {
+				t.Fatalf("Failed to read tasks: %v", err)
+			}
+			if !slices.EqualFunc(tasks, original, (*task.Task).Equal) {
+				t.Fatalf("Expected %v, got %v", original, tasks)
+			}
+			codec, _ := Codec(target)
+			for _, file := range taskFiles(t, reopened) {
+				if filepath.Ext(file) != codec.Ext() {
+					t.Fatalf("Expected only %s task files, got %v", codec.Ext(), taskFiles(t, reopened))
+				}
+			}
+			if change, _ := head(t).Change(); change.Action != repo.ActionMigrate {
+				t.Fatalf("Expected the migration to be committed, got %+v", change)
+			}
+		})
+	}
+
+	t.Run("Current", func(t *testing.T) {
+		before := head(t)
+
+		if err := p.Migrate(p.Config().SerializeMode); err != nil {
+			t.Fatalf("Failed to migrate: %v", err)
+		}
+		if after := head(t); after.Hash != before.Hash {
+			t.Fatalf("Expected no commit, got %q", after.Message)
+		}
+	})
+
+	t.Run("Unknown", func(t *testing.T) {
+		before := head(t)
+		files := taskFiles(t, p)
+
+		if err := p.Migrate(config.SerializeMode(99)); err == nil {
+			t.Fatalf("Expected an error for an unknown serialize mode")
+		}
+		if mode := p.Config().SerializeMode; mode != config.SerializeMarkdown {
+			t.Fatalf("Expected serialize mode %s, got %s", config.SerializeMarkdown, mode)
+		}
+		if got := taskFiles(t, p); !slices.Equal(got, files) {
+			t.Fatalf("Expected task files %v, got %v", files, got)
+		}
+		if after := head(t); after.Hash != before.Hash {
+			t.Fatalf("Expected no commit, got %q", after.Message)
+		}
+	})
+
+	t.Run("Migrated by another process", func(t *testing.T) {
+		p := testProject(t)
+		if _, err := p.Create("Task", "Description"); err != nil {
+			t.Fatalf("Failed to create task: %v", err)
+		}
+		other, err := reopen(p)
+		if err != nil {
+			t.Fatalf("Failed to open project: %v", err)
+		}
+		if err = other.Migrate(config.SerializeJSON); err != nil {
+			t.Fatalf("Failed to migrate: %v", err)
+		}
+
+		// p still has the markdown serialize mode it was opened with.
+		if err = p.Migrate(config.SerializeJSON); err != nil {
+			t.Fatalf("Failed to migrate: %v", err)
+		}
+		if mode := p.Config().SerializeMode; mode != config.SerializeJSON {
+			t.Fatalf("Expected serialize mode %s, got %s", config.SerializeJSON, mode)
+		}
+		tasks, err := p.Tasks()
+		if err != nil {
+			t.Fatalf("Failed to read tasks: %v", err)
+		}
+		if len(tasks) != 1 || tasks[0].Title != "Task" {
+			t.Fatalf("Expected the migrated task, got %v", tasks)
+		}
+	})
+
+	t.Run("Line endings", func(t *testing.T) {
+		for _, mode := range []config.SerializeMode{config.SerializeJSON, config.SerializeBinary} {
+			t.Run(mode.String(), func(t *testing.T) {
+				p := testProject(t)
+				if err := p.Migrate(mode); err != nil {
+					t.Fatalf("Failed to migrate: %v", err)
+				}
+
+				// Descriptions were stored as the browser sent them before
+				// line endings were normalized.
+				tk, err := task.New("Task", "")
+				if err != nil {
+					t.Fatalf("Failed to create task: %v", err)
+				}
+				tk.Description = "First line\r\nSecond line"
+				if err = p.tasks.Save([]*task.Task{tk}); err != nil {
+					t.Fatalf("Failed to save task: %v", err)
+				}
+
+				if err = p.Migrate(config.SerializeMarkdown); err != nil {
+					t.Fatalf("Failed to migrate: %v", err)
+				}
+				tasks, err := p.Tasks()
+				if err != nil {
+					t.Fatalf("Failed to read tasks: %v", err)
+				}
+				if len(tasks) != 1 || tasks[0].Description != "First line\nSecond line" {
+					t.Fatalf("Expected the description with \\n line endings, got %+v", tasks)
+				}
+			})
+		}
+	})
+
+	t.Run("ConfigVersion", func(t *testing.T) {
+		tests := []struct {
+			name          string
+			content       string
+			expectedError error
+		}{
+			{name: "Older", content: `{"SerializeMode": "markdown", "Author": {"Name": ""}}`},
+			{name: "Current", content: fmt.Sprintf(`{"Version": %d, "SerializeMode": "markdown"}`, config.Version)},
+			{name: "Newer", content: fmt.Sprintf(`{"Version": %d}`, config.Version+1), expectedError: config.ErrVersion},
+		}
+
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				path := p.Config().Path()
+				backup := path + ".v0.bak"
+				if err := p.Config().FS().Remove(backup); err != nil && !errors.Is(err, os.ErrNotExist) {
+					t.Fatalf("Failed to remove backup: %v", err)
+				}
+				if err := p.Config().FS().OverwriteFile(path, []byte(test.content)); err != nil {
+					t.Fatalf("Failed to write config: %v", err)
+				}
+
+				reopened, err := reopen(p)
+				if !errors.Is(err, test.expectedError) {
+					t.Fatalf("Expected error %v, got %v", test.expectedError, err)
+				}
+				if err != nil {
+					content, _ := p.Config().FS().ReadFile(path)
+					if string(content) != test.content {
+						t.Fatalf("Expected the config to be left alone, got %s", content)
+					}
+					return
+				}
+
+				tasks, err := reopened.Tasks()
+				if err != nil {
+					t.Fatalf("Failed to read tasks: %v", err)
+				}
+				if !slices.EqualFunc(tasks, original, (*task.Task).Equal) {
+					t.Fatalf("Expected %v, got %v", original, tasks)
+				}
+				if upgraded := p.Config().FS().FileExists(backup); upgraded != (test.name == "Older") {
+					t.Fatalf("Expected an upgrade only from an older version, got backup %v", upgraded)
+				}
+			})
+		}
+	})
+}
+
+func TestRecover(t *testing.T) {
+	testenv.Isolate(t)
+
+	modes := []struct {
+		name string
+		mode fio.RecoveryMode
+	}{
+		{name: "Skip", mode: fio.SkipCorrupt},
+		{name: "Truncate", mode: fio.TruncateCorrupt},
+	}
+
+	for _, test := range modes {
+		mode := test.mode
+		t.Run(test.name, func(t *testing.T) {
+			p := testProject(t)
+			if err := p.Migrate(config.SerializeBinary); err != nil {
+				t.Fatalf("Failed to migrate: %v", err)
+			}
+			var created []*task.Task
+			for _, title := range []string{"First", "Second"} {
+				tk, err := p.Create(title, "Description")
+				if err != nil {
+					t.Fatalf("Failed to create task: %v", err)
+				}
+				created = append(created, tk)
+			}
+
+			before, err := p.Revision("HEAD")
+			if err != nil {
+				t.Fatalf("Failed to resolve HEAD: %v", err)
+			}
+			reports, err := p.Recover(mode)
+			if err != nil {
+				t.Fatalf("Failed to recover: %v", err)
+			}
+			for file, report := range reports {
+				if !report.Clean() {
+					t.Fatalf("Expected %s to be clean, got %+v", file, report)
+				}
+			}
+			if after, _ := p.Revision("HEAD"); after.Hash != before.Hash {
+				t.Fatalf("Expected no commit for clean files, got %q", after.Message)
+			}
+
+			entries, err := p.Entries()
+			if err != nil {
+				t.Fatalf("Failed to read tasks: %v", err)
+			}
+			fsys := p.Config().FS()
+			damaged := filepath.Join(p.Config().TasksPath(), entries[0].File)
+			content, err := fsys.ReadFile(damaged)
+			if err != nil {
+				t.Fatalf("Failed to read task file: %v", err)
+			}
+			content[len(content)-1] ^= 0xFF
+			if err = fsys.OverwriteFile(damaged, content); err != nil {
+				t.Fatalf("Failed to write task file: %v", err)
+			}
+			if _, err = p.Tasks(); err == nil {
+				t.Fatalf("Expected an error reading a damaged task file")
+			}
+
+			reports, err = p.Recover(mode)
+			if err != nil {
+				t.Fatalf("Failed to recover: %v", err)
+			}
+			if report := reports[entries[0].File]; report == nil || report.Clean() || len(report.Records) != 0 {
+				t.Fatalf("Expected the damaged task to be lost, got %+v", report)
+			}
+			if fsys.FileExists(damaged) {
+				t.Fatalf("Expected the emptied task file to be removed")
+			}
+
+			tasks, err := p.Tasks()
+			if err != nil {
+				t.Fatalf("Failed to read tasks: %v", err)
+			}
+			if len(tasks) != 1 || !tasks[0].Equal(created[1]) {
+				t.Fatalf("Expected only %v, got %v", created[1], tasks)
+			}
+			order, err := fsys.ReadFile(filepath.Join(p.Config().TasksPath(), backlog.OrderFile))
+			if err != nil {
+				t.Fatalf("Failed to read order file: %v", err)
+			}
+			if strings.Contains(string(order), created[0].ID.String()) {
+				t.Fatalf("Expected %s to be dropped from the order, got %s", created[0].ID, order)
+			}
+
+			head, err := p.Revision("HEAD")
+			if err != nil {
+				t.Fatalf("Failed to resolve HEAD: %v", err)
+			}
+			if change, _ := head.Change(); change.Action != repo.ActionRecover {
+				t.Fatalf("Expected the recovery to be committed, got %q", head.Message)
+			}
+			rendered, err := fsys.ReadFile(p.Config().BacklogFile())
+			if err != nil {
+				t.Fatalf("Failed to read backlog: %v", err)
+			}
+			if strings.Contains(string(rendered), "First") || !strings.Contains(string(rendered), "Second") {
+				t.Fatalf("Expected only the second task in the backlog, got:\n%s", rendered)
+			}
+
+			if _, err = p.Create("Third", ""); err != nil {
+				t.Fatalf("Failed to create task after recovery: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package project
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/tedla-brandsema/tribble/internal/fio"
+	"github.com/tedla-brandsema/tribble/repo"
+	"github.com/tedla-brandsema/tribble/task"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// backlogSum holds the checksum of the markdown backlog as it was last
+// rendered, to tell edits made to it by hand from the rendering itself.
+const backlogSum = "backlog.sum"
+
+// ErrBacklogEdited means the markdown backlog was edited by hand and the edits
+// could not be read, so saving the tasks would overwrite them.
+var ErrBacklogEdited = errors.New("markdown backlog was edited and cannot be read")
+
+// write runs fn while holding the exclusive task lock. Edits made by hand to the
+// markdown backlog are imported first, as saving the tasks renders the backlog
+// again.
+func (p *Project) write(fn func() error) error {
+	return p.withLock(fio.ExclusiveLock, func() error {
+		err := p.importBacklog()
+		if err != nil {
+			return err
+		}
+		return fn()
+	})
+}
+
+// render writes tasks to the markdown backlog and records its checksum.
+func (p *Project) render(tasks []*task.Task) error {
+	var b []byte
+	buff := bytes.NewBuffer(b)
+
+	err := p.backlog.Codec().Encode(buff, tasks)
+	if err != nil {
+		return err
+	}
+
+	err = p.cfg.FS().OverwriteFile(p.backlog.Path(), buff.Bytes())
+	if err != nil {
+		return err
+	}
+	return p.cfg.FS().OverwriteFile(p.sumPath(), []byte(checksum(buff.Bytes())+"\n"))
+}
+
+// importBacklog saves the tasks of a markdown backlog that changed since it was
+// last rendered and commits them. Tasks that were changed are touched.
+func (p *Project) importBacklog() error {
+	path := p.backlog.Path()
+	content, err := p.cfg.FS().ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	edited, err := p.edited(content)
+	if err != nil || !edited {
+		return err
+	}
+
+	tasks, err := p.backlog.Codec().Decode(bytes.NewReader(content))
+	if err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrBacklogEdited, path, err)
+	}
+
+	stored, err := p.tasks.Load()
+	if err != nil {
+		return err
+	}
+	for _, t := range tasks {
+		i := indexOf(stored, t.ID)
+		if i < 0 || !t.Equal(stored[i]) {
+			t.Touch()
+		}
+	}
+
+	err = p.save(tasks)
+	if err != nil {
+		return err
+	}
+	return p.commit(repo.Change{Action: repo.ActionSave, Title: filepath.Base(path)})
+}
+
+// edited tells whether content, the markdown backlog, differs from its last
+// rendering. Without a recorded checksum it is compared to a rendering of the
+// stored tasks.
+func (p *Project) edited(content []byte) (bool, error) {
+	sum, err := p.cfg.FS().ReadFile(p.sumPath())
+	if err == nil {
+		return strings.TrimSpace(string(sum)) != checksum(content), nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return false, err
+	}
+
+	tasks, err := p.tasks.Load()
+	if err != nil {
+		return false, err
+	}
+
+	var b []byte
+	buff := bytes.NewBuffer(b)
+	err = p.backlog.Codec().Encode(buff, tasks)
+	if err != nil {
+		return false, err
+	}
+	return !bytes.Equal(buff.Bytes(), content), nil
+}
+
+func (p *Project) sumPath() string {
+	return filepath.Join(p.cfg.TribblePath(), backlogSum)
+}
+
+func checksum(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,83 @@
+package project
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/tedla-brandsema/tribble/repo"
+	"github.com/tedla-brandsema/tribble/task"
+	"time"
+)
+
+// Revision resolves rev, such as a hash or HEAD~2, to a revision.
+func (p *Project) Revision(rev string) (repo.Revision, error) {
+	return p.repo.Resolve(rev)
+}
+
+// RevisionAt returns the latest revision made at or before t.
+func (p *Project) RevisionAt(t time.Time) (repo.Revision, error) {
+	return p.repo.RevisionAt(t)
+}
+
+// RestoreTask brings a single task back to its state in the given revision,
+// leaving all other tasks as they are. A task that has since been deleted is
+// put back at the position it had in that revision. The restore is committed
+// as a new revision, history is never rewritten.
+func (p *Project) RestoreTask(id uuid.UUID, rev repo.Revision) (*task.Task, error) {
+	old, err := p.tasksAt(rev.Hash)
+	if err != nil {
+		return nil, err
+	}
+
+	position := indexOf(old, id)
+	if position < 0 {
+		return nil, fmt.Errorf("%w: %s in %s", ErrNotFound, id, rev.Hash)
+	}
+	restored := old[position]
+	restored.Touch()
+
+	change := &repo.Change{Action: repo.ActionRestore, TaskID: id, Title: restored.Title, Revision: rev.Hash}
+	err = p.mutate(change, func(tasks []*task.Task) ([]*task.Task, error) {
+		if i := indexOf(tasks, id); i >= 0 {
+			tasks[i] = restored
+			return tasks, nil
+		}
+
+		position = min(position, len(tasks))
+		return append(tasks[:position], append([]*task.Task{restored}, tasks[position:]...)...), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return restored, nil
+}
+
+// RestoreBacklog brings every task back to its state in the given revision.
+// Tasks created after that revision are removed. Like RestoreTask, it touches
+// the tasks it changes. The restore is committed as a new revision, so it can
+// itself be undone.
+func (p *Project) RestoreBacklog(rev repo.Revision) error {
+	old, err := p.tasksAt(rev.Hash)
+	if err != nil {
+		return err
+	}
+
+	return p.write(func() error {
+		tasks, err := p.tasks.Load()
+		if err != nil {
+			return err
+		}
+		for _, t := range old {
+			i := indexOf(tasks, t.ID)
+			if i < 0 || !t.Equal(tasks[i]) {
+				t.Touch()
+			}
+		}
+
+		err = p.save(old)
+		if err != nil {
+			return err
+		}
+		return p.commit(repo.Change{Action: repo.ActionRestore, Title: "backlog", Revision: rev.Hash})
+	})
+}
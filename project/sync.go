@@ -0,0 +1,108 @@
+package project
+
+import (
+	"github.com/tedla-brandsema/tribble/backlog"
+	"github.com/tedla-brandsema/tribble/config"
+	"github.com/tedla-brandsema/tribble/internal/fio"
+	"github.com/tedla-brandsema/tribble/repo"
+	"github.com/tedla-brandsema/tribble/task"
+)
+
+// DefaultRemote is the remote used when none is named.
+const DefaultRemote = "origin"
+
+// Fetch downloads the revisions of the remote without changing the tasks.
+func (p *Project) Fetch(remote string) error {
+	return p.repo.Fetch(remote)
+}
+
+// Pull merges the revisions of the remote into the local tasks and renders the
+// result to the markdown backlog. Task files are merged per task and field;
+// fields changed on both sides are listed by Conflicts until resolved.
+func (p *Project) Pull(remote string) (repo.MergeResult, error) {
+	return p.merge(func() (repo.MergeResult, error) {
+		return p.repo.Pull(remote)
+	})
+}
+
+// Push uploads the local revisions to the remote. It fails with
+// repo.ErrDiverged if the remote has revisions that have to be pulled first.
+func (p *Project) Push(remote string) error {
+	return p.withLock(fio.SharedLock, func() error {
+		return p.repo.Push(remote)
+	})
+}
+
+// merge runs a merge of the task repository with the task aware merge driver,
+// then picks up the merged tasks and records any conflicts.
+func (p *Project) merge(fn func() (repo.MergeResult, error)) (repo.MergeResult, error) {
+	var result repo.MergeResult
+
+	err := p.write(func() error {
+		var conflicts []task.Conflict
+		p.repo.SetMergeDriver(mergeDriver(&conflicts))
+		p.repo.SetMergeKey(mergeKey)
+		defer func() {
+			p.repo.SetMergeDriver(nil)
+			p.repo.SetMergeKey(nil)
+		}()
+
+		var err error
+		result, err = fn()
+		if err != nil {
+			return err
+		}
+		if result.Status == repo.UpToDate {
+			return nil
+		}
+
+		err = p.reload()
+		if err != nil {
+			return err
+		}
+		if len(conflicts) > 0 {
+			return p.recordConflicts(conflicts)
+		}
+		return nil
+	})
+
+	return result, err
+}
+
+// reload picks up the tasks that are checked out after a merge or a switch of
+// workspace. The serialize mode comes with the tasks, as it may have been
+// changed by a migration there, while the other settings are local and kept.
+func (p *Project) reload() error {
+	checkedOut, err := config.Load(p.cfg.Root(), config.Create(), config.WithFS(p.cfg.FS()))
+	if err != nil {
+		return err
+	}
+
+	workspace, err := p.repo.Workspace()
+	if err != nil {
+		return err
+	}
+
+	cfg := *p.cfg
+	cfg.SerializeMode = checkedOut.SerializeMode
+	cfg.Workspace = workspace
+	err = cfg.Save()
+	if err != nil {
+		return err
+	}
+
+	codec, err := Codec(cfg.SerializeMode)
+	if err != nil {
+		return err
+	}
+
+	p.cfg = &cfg
+	p.tasks = tasksFolder(&cfg, codec)
+	p.backlog = backlog.NewStore(cfg.FS(), cfg.BacklogFile(), backlog.Markdown)
+
+	tasks, err := p.tasks.Load()
+	if err != nil {
+		return err
+	}
+	return p.render(tasks)
+}
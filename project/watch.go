@@ -0,0 +1,64 @@
+package project
+
+import (
+	"context"
+	"github.com/tedla-brandsema/tribble/backlog"
+	"github.com/tedla-brandsema/tribble/config"
+	"github.com/tedla-brandsema/tribble/repo"
+	"log/slog"
+	"time"
+)
+
+// Watch follows the config files until ctx is done, so a long running tribble
+// picks up changed settings without a restart. Every change is applied to the
+// project before the subscribers of the returned watcher are told about it.
+// The config is loaded again with the options the project was opened with.
+func (p *Project) Watch(ctx context.Context, interval time.Duration) *config.Watcher {
+	w := config.NewWatcher(p.Config(), p.opts...)
+	w.Subscribe(func(c config.Change) {
+		if c.Err != nil {
+			return
+		}
+		if err := p.apply(c.Config); err != nil {
+			slog.Error("unable to apply config",
+				slog.String("path", c.Config.Path()),
+				slog.Any("error", err),
+			)
+		}
+	})
+	go w.Run(ctx, interval)
+	return w
+}
+
+// apply switches the project to cfg: the author and remotes of the repository
+// and the task file, which another tribble may have migrated, follow it, and
+// the backlog is rendered to the backlog path of cfg.
+func (p *Project) apply(changed *config.Config) error {
+	return p.write(func() error {
+		cfg := *changed
+		codec, err := Codec(cfg.SerializeMode)
+		if err != nil {
+			return err
+		}
+		cfg.Workspace, err = p.repo.Workspace()
+		if err != nil {
+			return err
+		}
+
+		err = p.repo.SetRemotes(repoRemotes(&cfg))
+		if err != nil {
+			return err
+		}
+		p.repo.SetAuthor(repo.Author{Name: cfg.Author.Name, Email: cfg.Author.Email})
+
+		p.cfg = &cfg
+		p.tasks = tasksFolder(&cfg, codec)
+		p.backlog = backlog.NewStore(cfg.FS(), cfg.BacklogFile(), backlog.Markdown)
+
+		tasks, err := p.tasks.Load()
+		if err != nil {
+			return err
+		}
+		return p.render(tasks)
+	})
+}
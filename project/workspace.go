@@ -0,0 +1,65 @@
+package project
+
+import (
+	"errors"
+	"github.com/tedla-brandsema/tribble/internal/fio"
+	"github.com/tedla-brandsema/tribble/repo"
+	"path/filepath"
+)
+
+// Workspaces returns the named backlogs kept in the task repository.
+func (p *Project) Workspaces() ([]repo.Workspace, error) {
+	return p.repo.Workspaces()
+}
+
+// CreateWorkspace creates a workspace from the revision from, or from the
+// current revision if from is empty, without switching to it.
+func (p *Project) CreateWorkspace(name, from string) (repo.Workspace, error) {
+	var w repo.Workspace
+
+	err := p.withLock(fio.SharedLock, func() error {
+		var err error
+		w, err = p.repo.CreateWorkspace(name, from)
+		return err
+	})
+
+	return w, err
+}
+
+// SwitchWorkspace checks out the tasks of another workspace, records it as the
+// current workspace in the config and renders its tasks to the markdown
+// backlog.
+func (p *Project) SwitchWorkspace(name string) error {
+	return p.write(func() error {
+		// The settings in tribble.cfg are kept in memory and written back by
+		// reload, so the committed copy can be restored for the switch.
+		err := p.repo.Discard(filepath.Base(p.cfg.Path()))
+		if err != nil {
+			return err
+		}
+
+		err = p.repo.SwitchWorkspace(name)
+		if err != nil {
+			if restoreErr := p.cfg.Save(); restoreErr != nil {
+				return errors.Join(err, restoreErr)
+			}
+			return err
+		}
+		return p.reload()
+	})
+}
+
+// MergeWorkspace merges the tasks of another workspace into the current
+// workspace, the same way Pull merges the tasks of a remote.
+func (p *Project) MergeWorkspace(name string) (repo.MergeResult, error) {
+	return p.merge(func() (repo.MergeResult, error) {
+		return p.repo.MergeWorkspace(name)
+	})
+}
+
+// DeleteWorkspace deletes a workspace other than the current one.
+func (p *Project) DeleteWorkspace(name string) error {
+	return p.withLock(fio.SharedLock, func() error {
+		return p.repo.DeleteWorkspace(name)
+	})
+}
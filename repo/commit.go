@@ -0,0 +1,233 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/google/uuid"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Author struct {
+	Name  string
+	Email string
+}
+
+type Action string
+
+const (
+	ActionCreate   Action = "create"
+	ActionUpdate   Action = "update"
+	ActionDelete   Action = "delete"
+	ActionSave     Action = "save"
+	ActionMigrate  Action = "migrate"
+	ActionRestore  Action = "restore"
+	ActionMerge    Action = "merge"
+	ActionConflict Action = "conflict"
+	ActionResolve  Action = "resolve"
+	ActionRecover  Action = "recover"
+)
+
+const (
+	actionTrailer   = "Tribble-Action"
+	taskTrailer     = "Tribble-Task"
+	revisionTrailer = "Tribble-Revision"
+)
+
+// Change describes a mutation of the backlog and is recorded in the message of
+// the commit that holds it.
+type Change struct {
+	Action Action
+	TaskID uuid.UUID
+	Title  string
+	// Revision is the earlier revision a restore went back to.
+	Revision plumbing.Hash
+}
+
+// Message formats the change as a commit message: a readable subject line
+// followed by trailers that ParseChange reads back.
+func (c Change) Message() string {
+	var b strings.Builder
+
+	b.WriteString(string(c.Action))
+	if c.Title != "" {
+		b.WriteString(": ")
+		b.WriteString(c.Title)
+	}
+	b.WriteString("\n\n")
+
+	fmt.Fprintf(&b, "%s: %s\n", actionTrailer, c.Action)
+	if c.TaskID != uuid.Nil {
+		fmt.Fprintf(&b, "%s: %s\n", taskTrailer, c.TaskID)
+	}
+	if !c.Revision.IsZero() {
+		fmt.Fprintf(&b, "%s: %s\n", revisionTrailer, c.Revision)
+	}
+
+	return b.String()
+}
+
+// ParseChange reads a change from a commit message written by Change.Message.
+func ParseChange(msg string) (Change, bool) {
+	var c Change
+
+	subject, _, _ := strings.Cut(msg, "\n")
+	if _, title, ok := strings.Cut(subject, ": "); ok {
+		c.Title = title
+	}
+
+	for _, line := range strings.Split(msg, "\n") {
+		key, value, ok := strings.Cut(line, ": ")
+		if !ok {
+			continue
+		}
+		switch key {
+		case actionTrailer:
+			c.Action = Action(strings.TrimSpace(value))
+		case taskTrailer:
+			id, err := uuid.Parse(strings.TrimSpace(value))
+			if err != nil {
+				return c, false
+			}
+			c.TaskID = id
+		case revisionTrailer:
+			hash := strings.TrimSpace(value)
+			if !plumbing.IsHash(hash) {
+				return c, false
+			}
+			c.Revision = plumbing.NewHash(hash)
+		}
+	}
+
+	return c, c.Action != ""
+}
+
+// Repository records changes to the files in the tribble folder as commits.
+type Repository struct {
+	mux    sync.RWMutex
+	repo   *git.Repository
+	author Author
+	driver MergeDriver
+	key    MergeKey
+}
+
+// Open opens the repository in path, initializing it if it does not exist.
+func Open(path string, author Author, opts ...Option) (*Repository, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	r, err := openRepo(path, o)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Repository{
+		repo:   r,
+		author: author,
+	}, nil
+}
+
+// SetAuthor changes the author recorded on the commits made from now on.
+func (r *Repository) SetAuthor(author Author) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	r.author = author
+}
+
+// Filesystem returns the worktree of the repository, the files that are
+// committed are written here.
+func (r *Repository) Filesystem() (billy.Filesystem, error) {
+	w, err := getWorktree(r.repo)
+	if err != nil {
+		return nil, err
+	}
+	return w.Filesystem, nil
+}
+
+// Commit stages the given paths, relative to the repository root, and commits
+// them with the message of the change. A directory stages every file in it,
+// and paths that no longer exist are staged as removed, so a file moved within
+// a staged directory shows up as a rename. No commit is made when none of the
+// paths changed, in which case the zero hash is returned.
+func (r *Repository) Commit(change Change, paths ...string) (plumbing.Hash, error) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	w, err := getWorktree(r.repo)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	for _, path := range paths {
+		err = stage(w, path)
+		if err != nil {
+			return plumbing.ZeroHash, fmt.Errorf("unable to stage %s: %w", path, err)
+		}
+	}
+
+	status, err := w.Status()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	if !staged(status, paths) {
+		return plumbing.ZeroHash, nil
+	}
+
+	// Removing the last file leaves an empty index, which go-git would
+	// otherwise refuse to commit.
+	return w.Commit(change.Message(), &git.CommitOptions{
+		Author:            r.signature(),
+		AllowEmptyCommits: true,
+	})
+}
+
+func (r *Repository) signature() *object.Signature {
+	return &object.Signature{
+		Name:  r.author.Name,
+		Email: r.author.Email,
+		When:  time.Now(),
+	}
+}
+
+func stage(w *git.Worktree, path string) error {
+	_, err := w.Filesystem.Lstat(path)
+	if err == nil {
+		_, err = w.Add(path)
+		return err
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	_, err = w.Remove(path)
+	if errors.Is(err, index.ErrEntryNotFound) {
+		return nil
+	}
+	return err
+}
+
+// staged reports whether any of the paths, or any file in one of them if it is
+// a directory, has staged changes.
+func staged(status git.Status, paths []string) bool {
+	for file, s := range status {
+		if s.Staging == git.Unmodified || s.Staging == git.Untracked {
+			continue
+		}
+		for _, path := range paths {
+			if file == path || strings.HasPrefix(file, path+"/") {
+				return true
+			}
+		}
+	}
+	return false
+}
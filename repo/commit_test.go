@@ -0,0 +1,127 @@
+package repo
+
+import (
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/google/uuid"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var testAuthor = Author{Name: "Test", Email: "test@example.com"}
+
+func TestChangeMessage(t *testing.T) {
+	tests := []struct {
+		name   string
+		change Change
+	}{
+		{
+			name:   "Task change",
+			change: Change{Action: ActionUpdate, TaskID: uuid.New(), Title: "Fix: the parser"},
+		},
+		{
+			name:   "Backlog change",
+			change: Change{Action: ActionSave, Title: "backlog"},
+		},
+		{
+			name:   "Restore",
+			change: Change{Action: ActionRestore, TaskID: uuid.New(), Title: "Title", Revision: plumbing.NewHash("0123456789abcdef0123456789abcdef01234567")},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			parsed, ok := ParseChange(test.change.Message())
+			if !ok {
+				t.Fatalf("Failed to parse message %q", test.change.Message())
+			}
+			if parsed != test.change {
+				t.Fatalf("Expected change %+v, got %+v", test.change, parsed)
+			}
+		})
+	}
+
+	t.Run("Foreign message", func(t *testing.T) {
+		if _, ok := ParseChange("Initial commit\n"); ok {
+			t.Fatalf("Expected message without trailers not to parse")
+		}
+	})
+}
+
+func TestCommit(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "tasks.json")
+
+	r, err := Open(dir, testAuthor)
+	if err != nil {
+		t.Fatalf("Failed to open repository: %v", err)
+	}
+
+	change := Change{Action: ActionCreate, TaskID: uuid.New(), Title: "First"}
+
+	t.Run("Commit new file", func(t *testing.T) {
+		if err := os.WriteFile(path, []byte("[1]"), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+
+		hash, err := r.Commit(change, "tasks.json")
+		if err != nil {
+			t.Fatalf("Failed to commit: %v", err)
+		}
+
+		commit, err := r.repo.CommitObject(hash)
+		if err != nil {
+			t.Fatalf("Failed to read commit: %v", err)
+		}
+		if commit.Author.Name != testAuthor.Name || commit.Author.Email != testAuthor.Email {
+			t.Fatalf("Expected author %+v, got %+v", testAuthor, commit.Author)
+		}
+		parsed, ok := ParseChange(commit.Message)
+		if !ok || parsed != change {
+			t.Fatalf("Expected change %+v, got %+v", change, parsed)
+		}
+	})
+
+	t.Run("Skip unchanged file", func(t *testing.T) {
+		hash, err := r.Commit(Change{Action: ActionUpdate}, "tasks.json")
+		if err != nil {
+			t.Fatalf("Failed to commit: %v", err)
+		}
+		if hash != plumbing.ZeroHash {
+			t.Fatalf("Expected no commit, got %s", hash)
+		}
+	})
+
+	t.Run("Commit removed file", func(t *testing.T) {
+		if err := os.Remove(path); err != nil {
+			t.Fatalf("Failed to remove file: %v", err)
+		}
+
+		hash, err := r.Commit(Change{Action: ActionDelete}, "tasks.json")
+		if err != nil {
+			t.Fatalf("Failed to commit: %v", err)
+		}
+
+		commit, err := r.repo.CommitObject(hash)
+		if err != nil {
+			t.Fatalf("Failed to read commit: %v", err)
+		}
+		if _, err = commit.File("tasks.json"); err == nil {
+			t.Fatalf("Expected file to be removed from the commit")
+		}
+	})
+
+	t.Run("Reopen", func(t *testing.T) {
+		reopened, err := Open(dir, testAuthor)
+		if err != nil {
+			t.Fatalf("Failed to reopen repository: %v", err)
+		}
+		head, err := reopened.repo.Head()
+		if err != nil {
+			t.Fatalf("Failed to read head: %v", err)
+		}
+		if head.Hash().IsZero() {
+			t.Fatalf("Expected head to point at a commit")
+		}
+	})
+}
@@ -0,0 +1,188 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"io"
+	"time"
+)
+
+var ErrRevisionNotFound = errors.New("revision not found")
+
+// Revision is a single commit in the repository.
+type Revision struct {
+	Hash    plumbing.Hash
+	Parents []plumbing.Hash
+	Author  Author
+	When    time.Time
+	Message string
+}
+
+// Change returns the change recorded in the commit message, if any.
+func (rev Revision) Change() (Change, bool) {
+	return ParseChange(rev.Message)
+}
+
+func newRevision(c *object.Commit) Revision {
+	return Revision{
+		Hash:    c.Hash,
+		Parents: c.ParentHashes,
+		Author:  Author{Name: c.Author.Name, Email: c.Author.Email},
+		When:    c.Author.When,
+		Message: c.Message,
+	}
+}
+
+// Log returns all revisions reachable from HEAD, newest first. An empty
+// repository has no revisions.
+func (r *Repository) Log() ([]Revision, error) {
+	return r.log(&git.LogOptions{Order: git.LogOrderCommitterTime})
+}
+
+// LogPaths returns the revisions reachable from HEAD that changed a file whose
+// path, relative to the repository root, matches match, newest first.
+func (r *Repository) LogPaths(match func(path string) bool) ([]Revision, error) {
+	return r.log(&git.LogOptions{Order: git.LogOrderCommitterTime, PathFilter: match})
+}
+
+func (r *Repository) log(options *git.LogOptions) ([]Revision, error) {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+
+	iter, err := r.repo.Log(options)
+	if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	var revisions []Revision
+	err = iter.ForEach(func(c *object.Commit) error {
+		revisions = append(revisions, newRevision(c))
+		return nil
+	})
+	if err != nil && !errors.Is(err, storer.ErrStop) {
+		return nil, err
+	}
+
+	return revisions, nil
+}
+
+// Resolve returns the revision named by rev, which may be anything git
+// understands as a revision, such as a full or abbreviated hash, a branch name
+// or HEAD~2.
+func (r *Repository) Resolve(rev string) (Revision, error) {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+
+	hash, err := r.repo.ResolveRevision(plumbing.Revision(rev))
+	if err != nil {
+		return Revision{}, fmt.Errorf("%w: %s", ErrRevisionNotFound, rev)
+	}
+
+	c, err := r.repo.CommitObject(*hash)
+	if err != nil {
+		return Revision{}, err
+	}
+	return newRevision(c), nil
+}
+
+// RevisionAt returns the latest revision made at or before t.
+func (r *Repository) RevisionAt(t time.Time) (Revision, error) {
+	log, err := r.Log()
+	if err != nil {
+		return Revision{}, err
+	}
+
+	for _, rev := range log {
+		if !rev.When.After(t) {
+			return rev, nil
+		}
+	}
+	return Revision{}, fmt.Errorf("%w: no revision before %s", ErrRevisionNotFound, t.Format(time.RFC3339))
+}
+
+// ReadFile returns the contents of the file at path, relative to the
+// repository root, as it was in the given revision. It reports false if the
+// file did not exist in that revision.
+func (r *Repository) ReadFile(hash plumbing.Hash, path string) ([]byte, bool, error) {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+
+	c, err := r.repo.CommitObject(hash)
+	if err != nil {
+		return nil, false, err
+	}
+
+	f, err := c.File(path)
+	if errors.Is(err, object.ErrFileNotFound) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	reader, err := f.Reader()
+	if err != nil {
+		return nil, false, err
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, false, err
+	}
+	return content, true, nil
+}
+
+// ReadDir returns the contents of the files directly in the directory dir,
+// relative to the repository root, as they were in the given revision. The
+// files are keyed by name; a directory that did not exist has no files.
+func (r *Repository) ReadDir(hash plumbing.Hash, dir string) (map[string][]byte, error) {
+	return r.ReadDirFunc(hash, dir, func(string) bool { return true })
+}
+
+// ReadDirFunc is like ReadDir, but only reads the files whose name matches
+// match.
+func (r *Repository) ReadDirFunc(hash plumbing.Hash, dir string, match func(name string) bool) (map[string][]byte, error) {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+
+	c, err := r.repo.CommitObject(hash)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := c.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	sub, err := tree.Tree(dir)
+	if errors.Is(err, object.ErrDirectoryNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string][]byte)
+	for _, entry := range sub.Entries {
+		if !entry.Mode.IsFile() || !match(entry.Name) {
+			continue
+		}
+		content, err := r.readBlob(entry.Hash)
+		if err != nil {
+			return nil, err
+		}
+		files[entry.Name] = content
+	}
+	return files, nil
+}
@@ -0,0 +1,336 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"io"
+	"slices"
+	"strings"
+)
+
+var ErrMergeConflict = errors.New("merge conflict")
+
+// ConflictError lists the files that were changed on both sides of a merge
+// and could not be merged. It matches ErrMergeConflict.
+type ConflictError struct {
+	Paths []string
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%s in %s", ErrMergeConflict, strings.Join(e.Paths, ", "))
+}
+
+func (e *ConflictError) Is(target error) bool {
+	return target == ErrMergeConflict
+}
+
+// MergeDriver combines the contents of a file that was changed on both sides
+// of a merge. base, ours and theirs are nil when the file does not exist on
+// that side; returning nil content removes the file.
+type MergeDriver func(path string, base, ours, theirs []byte) ([]byte, error)
+
+// SetMergeDriver sets the driver used for files changed on both sides of a
+// merge. Without a driver such files are reported as a ConflictError.
+func (r *Repository) SetMergeDriver(driver MergeDriver) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	r.driver = driver
+}
+
+// MergeKey returns the key of a file: files with the same key on both sides of
+// a merge are the same file, also when one side renamed it. Files the key does
+// not apply to are returned by path.
+type MergeKey func(path string) string
+
+// SetMergeKey sets the key by which files are matched in a merge. Without a
+// key files are matched by path.
+func (r *Repository) SetMergeKey(key MergeKey) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	r.key = key
+}
+
+type MergeStatus int
+
+const (
+	// UpToDate means the current branch already contained the other revision.
+	UpToDate MergeStatus = iota
+	// FastForward means the current branch was moved to the other revision.
+	FastForward
+	// Merged means a merge commit was made.
+	Merged
+)
+
+// MergeResult tells how a merge went and which revision the current branch
+// points to afterwards.
+type MergeResult struct {
+	Status MergeStatus
+	Hash   plumbing.Hash
+}
+
+// merge merges the revision theirs into the current branch. Files changed on
+// one side only are taken from that side. Nothing is changed when a file
+// changed on both sides cannot be merged.
+func (r *Repository) merge(theirs plumbing.Hash, change Change) (MergeResult, error) {
+	w, err := getWorktree(r.repo)
+	if err != nil {
+		return MergeResult{}, err
+	}
+
+	head, err := r.repo.Head()
+	if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		// Nothing has been committed yet, so their history is taken as is.
+		return r.fastForward(w, theirs)
+	}
+	if err != nil {
+		return MergeResult{}, err
+	}
+	if head.Hash() == theirs {
+		return MergeResult{Status: UpToDate, Hash: theirs}, nil
+	}
+
+	ours, err := r.repo.CommitObject(head.Hash())
+	if err != nil {
+		return MergeResult{}, err
+	}
+	other, err := r.repo.CommitObject(theirs)
+	if err != nil {
+		return MergeResult{}, err
+	}
+
+	bases, err := ours.MergeBase(other)
+	if err != nil {
+		return MergeResult{}, err
+	}
+	var base *object.Commit
+	if len(bases) > 0 {
+		base = bases[0]
+		switch base.Hash {
+		case theirs:
+			return MergeResult{Status: UpToDate, Hash: ours.Hash}, nil
+		case ours.Hash:
+			return r.fastForward(w, theirs)
+		}
+	}
+
+	merged, err := r.mergeTrees(base, ours, other)
+	if err != nil {
+		return MergeResult{}, err
+	}
+
+	for _, path := range sortedKeys(merged) {
+		content := merged[path]
+		if content == nil {
+			err = w.Filesystem.Remove(path)
+		} else {
+			err = util.WriteFile(w.Filesystem, path, content, 0644)
+		}
+		if err != nil {
+			return MergeResult{}, fmt.Errorf("unable to write %s: %w", path, err)
+		}
+		if err = stage(w, path); err != nil {
+			return MergeResult{}, fmt.Errorf("unable to stage %s: %w", path, err)
+		}
+	}
+
+	hash, err := w.Commit(change.Message(), &git.CommitOptions{
+		Author:            r.signature(),
+		Parents:           []plumbing.Hash{ours.Hash, theirs},
+		AllowEmptyCommits: true,
+	})
+	if err != nil {
+		return MergeResult{}, err
+	}
+	return MergeResult{Status: Merged, Hash: hash}, nil
+}
+
+func (r *Repository) fastForward(w *git.Worktree, hash plumbing.Hash) (MergeResult, error) {
+	// Point the branch at the revision first, a branch without commits cannot
+	// be reset.
+	branch, err := r.branch()
+	if err != nil {
+		return MergeResult{}, err
+	}
+	err = r.repo.Storer.SetReference(plumbing.NewHashReference(branch, hash))
+	if err != nil {
+		return MergeResult{}, err
+	}
+
+	err = w.Reset(&git.ResetOptions{Commit: hash, Mode: git.HardReset})
+	if err != nil {
+		return MergeResult{}, fmt.Errorf("unable to fast-forward to %s: %w", hash, err)
+	}
+	return MergeResult{Status: FastForward, Hash: hash}, nil
+}
+
+// mergeTrees returns the new contents of every file our side has to change,
+// nil for files that have to be removed. Files are matched by their key; a
+// file renamed on one side takes the name of that side, and when both renamed
+// it our name is kept.
+func (r *Repository) mergeTrees(base, ours, theirs *object.Commit) (map[string][]byte, error) {
+	var trees [3]map[string]keyedFile
+	for i, c := range []*object.Commit{base, ours, theirs} {
+		files, err := treeFiles(c)
+		if err != nil {
+			return nil, err
+		}
+		trees[i] = r.byKey(files)
+	}
+	b, o, t := trees[0], trees[1], trees[2]
+
+	keys := make(map[string]bool)
+	for _, tree := range trees {
+		for key := range tree {
+			keys[key] = true
+		}
+	}
+
+	merged := make(map[string][]byte)
+	var conflicts []string
+	for _, key := range sortedKeys(keys) {
+		bf, of, tf := b[key], o[key], t[key]
+
+		path := of.path
+		switch {
+		case of.path == "":
+			path = tf.path
+		case of.path == bf.path && tf.path != "":
+			path = tf.path
+		}
+
+		var content []byte
+		switch {
+		case of.hash == tf.hash, bf.hash == tf.hash:
+			if path == of.path {
+				continue
+			}
+			c, err := r.readBlob(of.hash)
+			if err != nil {
+				return nil, err
+			}
+			content = c
+		case bf.hash == of.hash:
+			c, err := r.readBlob(tf.hash)
+			if err != nil {
+				return nil, err
+			}
+			content = c
+		case r.driver == nil:
+			conflicts = append(conflicts, path)
+			continue
+		default:
+			var sides [3][]byte
+			for i, hash := range []plumbing.Hash{bf.hash, of.hash, tf.hash} {
+				c, err := r.readBlob(hash)
+				if err != nil {
+					return nil, err
+				}
+				sides[i] = c
+			}
+
+			c, err := r.driver(path, sides[0], sides[1], sides[2])
+			if errors.Is(err, ErrMergeConflict) {
+				conflicts = append(conflicts, path)
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("unable to merge %s: %w", path, err)
+			}
+			content = c
+		}
+
+		if of.path != "" && (path != of.path || content == nil) {
+			merged[of.path] = nil
+		}
+		if content != nil {
+			merged[path] = content
+		}
+	}
+
+	if len(conflicts) > 0 {
+		return nil, &ConflictError{Paths: conflicts}
+	}
+	return merged, nil
+}
+
+// keyedFile is a file of a tree, found by its merge key. The zero value is a
+// file that does not exist.
+type keyedFile struct {
+	path string
+	hash plumbing.Hash
+}
+
+func (r *Repository) byKey(files map[string]plumbing.Hash) map[string]keyedFile {
+	keyed := make(map[string]keyedFile, len(files))
+	for path, hash := range files {
+		key := path
+		if r.key != nil {
+			key = r.key(path)
+		}
+		keyed[key] = keyedFile{path: path, hash: hash}
+	}
+	return keyed
+}
+
+func treeFiles(c *object.Commit) (map[string]plumbing.Hash, error) {
+	files := make(map[string]plumbing.Hash)
+	if c == nil {
+		return files, nil
+	}
+
+	tree, err := c.Tree()
+	if err != nil {
+		return nil, err
+	}
+	err = tree.Files().ForEach(func(f *object.File) error {
+		files[f.Name] = f.Hash
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// readBlob returns the contents of a blob, nil for the zero hash.
+func (r *Repository) readBlob(hash plumbing.Hash) ([]byte, error) {
+	if hash.IsZero() {
+		return nil, nil
+	}
+
+	blob, err := r.repo.BlobObject(hash)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := blob.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	if content == nil {
+		content = []byte{}
+	}
+	return content, nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
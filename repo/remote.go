@@ -0,0 +1,187 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"strings"
+)
+
+var (
+	ErrUnknownRemote = errors.New("unknown remote")
+	ErrDiverged      = errors.New("local and remote history have diverged")
+)
+
+// Remote is another repository the tasks are synchronized with, such as a
+// bare repository shared by a team.
+type Remote struct {
+	Name string
+	URL  string
+}
+
+// SetRemotes makes the remotes of the repository match the given remotes.
+// Remotes that are not listed are removed.
+func (r *Repository) SetRemotes(remotes []Remote) error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	cfg, err := r.repo.Config()
+	if err != nil {
+		return err
+	}
+
+	wanted := make(map[string]bool, len(remotes))
+	for _, remote := range remotes {
+		wanted[remote.Name] = true
+
+		rc := &config.RemoteConfig{
+			Name: remote.Name,
+			URLs: []string{remote.URL},
+			Fetch: []config.RefSpec{
+				config.RefSpec(fmt.Sprintf(config.DefaultFetchRefSpec, remote.Name)),
+			},
+		}
+		if err = rc.Validate(); err != nil {
+			return fmt.Errorf("remote %q: %w", remote.Name, err)
+		}
+		cfg.Remotes[remote.Name] = rc
+	}
+	for name := range cfg.Remotes {
+		if !wanted[name] {
+			delete(cfg.Remotes, name)
+		}
+	}
+
+	return r.repo.SetConfig(cfg)
+}
+
+// Remotes returns the remotes of the repository.
+func (r *Repository) Remotes() ([]Remote, error) {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+
+	list, err := r.repo.Remotes()
+	if err != nil {
+		return nil, err
+	}
+
+	remotes := make([]Remote, 0, len(list))
+	for _, remote := range list {
+		rc := remote.Config()
+		var url string
+		if len(rc.URLs) > 0 {
+			url = rc.URLs[0]
+		}
+		remotes = append(remotes, Remote{Name: rc.Name, URL: url})
+	}
+	return remotes, nil
+}
+
+// Fetch downloads the branches of the remote without touching the worktree.
+// Fetching from an empty remote is not an error.
+func (r *Repository) Fetch(remote string) error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	return r.fetch(remote)
+}
+
+func (r *Repository) fetch(remote string) error {
+	if _, err := r.repo.Remote(remote); err != nil {
+		return fmt.Errorf("%w: %s", ErrUnknownRemote, remote)
+	}
+
+	err := r.repo.Fetch(&git.FetchOptions{RemoteName: remote})
+	switch {
+	case errors.Is(err, git.NoErrAlreadyUpToDate), errors.Is(err, transport.ErrEmptyRemoteRepository):
+		return nil
+	case err != nil:
+		return fmt.Errorf("unable to fetch %s: %w", remote, err)
+	}
+	return nil
+}
+
+// Pull fetches the remote and merges its copy of the current branch into the
+// current branch. When both have new revisions, files changed on one side only
+// are taken from that side and files changed on both sides are handed to the
+// merge driver.
+func (r *Repository) Pull(remote string) (MergeResult, error) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	err := r.fetch(remote)
+	if err != nil {
+		return MergeResult{}, err
+	}
+
+	branch, err := r.branch()
+	if err != nil {
+		return MergeResult{}, err
+	}
+
+	tracking := plumbing.NewRemoteReferenceName(remote, branch.Short())
+	ref, err := r.repo.Reference(tracking, true)
+	if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		// The remote does not have the branch yet.
+		return MergeResult{}, nil
+	}
+	if err != nil {
+		return MergeResult{}, err
+	}
+
+	return r.merge(ref.Hash(), Change{Action: ActionMerge, Title: remote + "/" + branch.Short()})
+}
+
+// Push uploads the current branch to the remote. It returns ErrDiverged if the
+// remote has revisions that have not been pulled yet.
+func (r *Repository) Push(remote string) error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	if _, err := r.repo.Remote(remote); err != nil {
+		return fmt.Errorf("%w: %s", ErrUnknownRemote, remote)
+	}
+
+	branch, err := r.branch()
+	if err != nil {
+		return err
+	}
+
+	spec := config.RefSpec(fmt.Sprintf("%s:%s", branch, branch))
+	err = r.repo.Push(&git.PushOptions{
+		RemoteName: remote,
+		RefSpecs:   []config.RefSpec{spec},
+	})
+	switch {
+	case errors.Is(err, git.NoErrAlreadyUpToDate):
+		return nil
+	case nonFastForward(err):
+		return fmt.Errorf("unable to push to %s: %w", remote, ErrDiverged)
+	case err != nil:
+		return fmt.Errorf("unable to push to %s: %w", remote, err)
+	}
+	return nil
+}
+
+// nonFastForward reports whether a push was refused because the remote has
+// revisions we do not have. go-git does not wrap ErrNonFastForwardUpdate for
+// pushes, so the message has to be matched.
+func nonFastForward(err error) bool {
+	return err != nil && strings.HasPrefix(err.Error(), git.ErrNonFastForwardUpdate.Error())
+}
+
+// branch returns the branch HEAD points at, which need not have any commits
+// yet.
+func (r *Repository) branch() (plumbing.ReferenceName, error) {
+	head, err := r.repo.Storer.Reference(plumbing.HEAD)
+	if err != nil {
+		return "", err
+	}
+	if head.Type() != plumbing.SymbolicReference || !head.Target().IsBranch() {
+		return "", errors.New("HEAD is not on a branch")
+	}
+	return head.Target(), nil
+}
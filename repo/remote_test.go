@@ -0,0 +1,213 @@
+package repo
+
+import (
+	"bytes"
+	"errors"
+	"github.com/go-git/go-git/v5"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// newRemotePair returns two repositories that share a bare remote on disk.
+func newRemotePair(t *testing.T) (string, *Repository, *Repository) {
+	t.Helper()
+
+	// go-git talks to repositories on disk through the git binary.
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	bare := filepath.Join(t.TempDir(), "shared.git")
+	if _, err := git.PlainInit(bare, true); err != nil {
+		t.Fatalf("Failed to create bare repository: %v", err)
+	}
+
+	repos := make([]*Repository, 2)
+	for i := range repos {
+		dir := t.TempDir()
+		r, err := Open(dir, testAuthor)
+		if err != nil {
+			t.Fatalf("Failed to open repository: %v", err)
+		}
+		if err = r.SetRemotes([]Remote{{Name: "origin", URL: bare}}); err != nil {
+			t.Fatalf("Failed to set remotes: %v", err)
+		}
+		repos[i] = r
+	}
+
+	return bare, repos[0], repos[1]
+}
+
+func writeAndCommit(t *testing.T, r *Repository, path, content string) {
+	t.Helper()
+
+	w, err := r.repo.Worktree()
+	if err != nil {
+		t.Fatalf("Failed to open worktree: %v", err)
+	}
+	root := w.Filesystem.Root()
+	if err = os.WriteFile(filepath.Join(root, path), []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if _, err = r.Commit(Change{Action: ActionSave, Title: path}, path); err != nil {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+}
+
+func readWorktree(t *testing.T, r *Repository, path string) string {
+	t.Helper()
+
+	w, err := r.repo.Worktree()
+	if err != nil {
+		t.Fatalf("Failed to open worktree: %v", err)
+	}
+	content, err := os.ReadFile(filepath.Join(w.Filesystem.Root(), path))
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", path, err)
+	}
+	return string(content)
+}
+
+func TestSetRemotes(t *testing.T) {
+	r, err := Open(t.TempDir(), testAuthor)
+	if err != nil {
+		t.Fatalf("Failed to open repository: %v", err)
+	}
+
+	err = r.SetRemotes([]Remote{{Name: "origin", URL: "/srv/a.git"}, {Name: "backup", URL: "/srv/b.git"}})
+	if err != nil {
+		t.Fatalf("Failed to set remotes: %v", err)
+	}
+	err = r.SetRemotes([]Remote{{Name: "origin", URL: "/srv/c.git"}})
+	if err != nil {
+		t.Fatalf("Failed to set remotes: %v", err)
+	}
+
+	remotes, err := r.Remotes()
+	if err != nil {
+		t.Fatalf("Failed to list remotes: %v", err)
+	}
+	if len(remotes) != 1 || remotes[0] != (Remote{Name: "origin", URL: "/srv/c.git"}) {
+		t.Fatalf("Expected only origin at /srv/c.git, got %+v", remotes)
+	}
+
+	if err = r.Fetch("backup"); !errors.Is(err, ErrUnknownRemote) {
+		t.Fatalf("Expected error %v, got %v", ErrUnknownRemote, err)
+	}
+}
+
+func TestSync(t *testing.T) {
+	_, alice, bob := newRemotePair(t)
+
+	t.Run("Pull from empty remote", func(t *testing.T) {
+		result, err := bob.Pull("origin")
+		if err != nil {
+			t.Fatalf("Failed to pull: %v", err)
+		}
+		if result.Status != UpToDate {
+			t.Fatalf("Expected status %d, got %d", UpToDate, result.Status)
+		}
+	})
+
+	t.Run("Push and fast-forward", func(t *testing.T) {
+		writeAndCommit(t, alice, "tasks.md", "first\n")
+		if err := alice.Push("origin"); err != nil {
+			t.Fatalf("Failed to push: %v", err)
+		}
+
+		result, err := bob.Pull("origin")
+		if err != nil {
+			t.Fatalf("Failed to pull: %v", err)
+		}
+		if result.Status != FastForward {
+			t.Fatalf("Expected status %d, got %d", FastForward, result.Status)
+		}
+		if got := readWorktree(t, bob, "tasks.md"); got != "first\n" {
+			t.Fatalf("Expected %q, got %q", "first\n", got)
+		}
+	})
+
+	t.Run("Push diverged", func(t *testing.T) {
+		writeAndCommit(t, bob, "bob.md", "bob\n")
+		if err := bob.Push("origin"); err != nil {
+			t.Fatalf("Failed to push: %v", err)
+		}
+
+		writeAndCommit(t, alice, "alice.md", "alice\n")
+		if err := alice.Push("origin"); !errors.Is(err, ErrDiverged) {
+			t.Fatalf("Expected error %v, got %v", ErrDiverged, err)
+		}
+	})
+
+	t.Run("Merge separate files", func(t *testing.T) {
+		result, err := alice.Pull("origin")
+		if err != nil {
+			t.Fatalf("Failed to pull: %v", err)
+		}
+		if result.Status != Merged {
+			t.Fatalf("Expected status %d, got %d", Merged, result.Status)
+		}
+
+		commit, err := alice.repo.CommitObject(result.Hash)
+		if err != nil {
+			t.Fatalf("Failed to read commit: %v", err)
+		}
+		if commit.NumParents() != 2 {
+			t.Fatalf("Expected a merge commit with 2 parents, got %d", commit.NumParents())
+		}
+		if got := readWorktree(t, alice, "bob.md"); got != "bob\n" {
+			t.Fatalf("Expected %q, got %q", "bob\n", got)
+		}
+		if got := readWorktree(t, alice, "alice.md"); got != "alice\n" {
+			t.Fatalf("Expected %q, got %q", "alice\n", got)
+		}
+
+		if err = alice.Push("origin"); err != nil {
+			t.Fatalf("Failed to push merge: %v", err)
+		}
+	})
+
+	t.Run("Conflict", func(t *testing.T) {
+		if _, err := bob.Pull("origin"); err != nil {
+			t.Fatalf("Failed to pull: %v", err)
+		}
+
+		writeAndCommit(t, bob, "tasks.md", "bob's\n")
+		if err := bob.Push("origin"); err != nil {
+			t.Fatalf("Failed to push: %v", err)
+		}
+		writeAndCommit(t, alice, "tasks.md", "alice's\n")
+
+		_, err := alice.Pull("origin")
+		var conflict *ConflictError
+		if !errors.As(err, &conflict) || !errors.Is(err, ErrMergeConflict) {
+			t.Fatalf("Expected a conflict, got %v", err)
+		}
+		if len(conflict.Paths) != 1 || conflict.Paths[0] != "tasks.md" {
+			t.Fatalf("Expected conflict in tasks.md, got %v", conflict.Paths)
+		}
+		if got := readWorktree(t, alice, "tasks.md"); got != "alice's\n" {
+			t.Fatalf("Expected worktree to be left alone, got %q", got)
+		}
+	})
+
+	t.Run("Merge driver", func(t *testing.T) {
+		alice.SetMergeDriver(func(path string, base, ours, theirs []byte) ([]byte, error) {
+			return bytes.Join([][]byte{ours, theirs}, nil), nil
+		})
+		defer alice.SetMergeDriver(nil)
+
+		result, err := alice.Pull("origin")
+		if err != nil {
+			t.Fatalf("Failed to pull: %v", err)
+		}
+		if result.Status != Merged {
+			t.Fatalf("Expected status %d, got %d", Merged, result.Status)
+		}
+		if got := readWorktree(t, alice, "tasks.md"); got != "alice's\nbob's\n" {
+			t.Fatalf("Expected merged content, got %q", got)
+		}
+	})
+}
@@ -4,30 +4,80 @@ import (
 	"errors"
 	"fmt"
 	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
 	"github.com/go-git/go-billy/v5/osfs"
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing/cache"
+	"github.com/go-git/go-git/v5/storage"
 	"github.com/go-git/go-git/v5/storage/filesystem"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"github.com/tedla-brandsema/tribble/internal/fio"
 	"log/slog"
 	"os"
-	"sync"
+	"path/filepath"
 )
 
-var (
-	mux = sync.RWMutex{}
-	fs  billy.Filesystem
+const (
+	gitFolder = ".git"
+	// movingFolder holds the git files of a repository that is being moved
+	// to gitFolder, so an interrupted move can be completed.
+	movingFolder = ".git.moving"
 )
 
-func openRepo(path string) (*git.Repository, error) {
-	mux.Lock()
-	defer mux.Unlock()
+// gitFiles are the files and folders git keeps in a repository folder.
+var gitFiles = []string{
+	"HEAD", "ORIG_HEAD", "FETCH_HEAD", "MERGE_HEAD",
+	"config", "description", "index", "packed-refs", "shallow",
+	"branches", "hooks", "info", "logs", "modules", "objects", "refs",
+}
+
+type options struct {
+	// worktree is the worktree of a repository kept in memory.
+	worktree billy.Filesystem
+}
+
+// Option changes how Open stores a repository.
+type Option func(*options)
+
+// InMemory keeps the repository in memory: the git objects and references in
+// memory storage and the worktree in a memfs, so nothing is written to the
+// folder passed to Open.
+func InMemory() Option {
+	return InMemoryFS(memfs.New())
+}
+
+// InMemoryFS is InMemory with fs as the worktree, for files that are written
+// to fs by others before they are committed.
+func InMemoryFS(fs billy.Filesystem) Option {
+	return func(o *options) {
+		o.worktree = fs
+	}
+}
+
+func openRepo(path string, opts options) (*git.Repository, error) {
+	var fs billy.Filesystem
+	var storer storage.Storer
+	if opts.worktree != nil {
+		fs = opts.worktree
+		storer = memory.NewStorage()
+	} else {
+		fs = osfs.New(path)
+		err := moveRepo(path)
+		if err != nil {
+			return nil, fmt.Errorf("unable to move repository to %s: %w", gitFolder, err)
+		}
+
+		dot, err := fs.Chroot(gitFolder)
+		if err != nil {
+			return nil, err
+		}
+		storer = filesystem.NewStorage(dot, cache.NewObjectLRUDefault())
+	}
 
-	fs = osfs.New(path)
-	storage := filesystem.NewStorage(fs, cache.NewObjectLRUDefault())
-	r, err := git.Open(storage, fs)
+	r, err := git.Open(storer, fs)
 	if err != nil && errors.Is(err, git.ErrRepositoryNotExists) {
 		slog.Info("repository does not exit: initializing repository")
-		r, err = git.Init(storage, fs)
+		r, err = git.Init(storer, fs)
 	}
 	if err != nil {
 		return nil, err
@@ -35,10 +85,43 @@ func openRepo(path string) (*git.Repository, error) {
 	return r, nil
 }
 
-func deleteRepo(path string) error {
-	mux.Lock()
-	defer mux.Unlock()
+// moveRepo moves a repository that keeps its git files directly in path, as
+// repositories did before they moved to gitFolder, so its history is kept.
+// Such a repository has a gitFolder file pointing git at path. The files are
+// gathered in movingFolder first, which replaces that file once they are all
+// there.
+func moveRepo(path string) error {
+	dot := filepath.Join(path, gitFolder)
+	moving := filepath.Join(path, movingFolder)
+	if info, err := os.Stat(dot); err == nil && info.IsDir() {
+		return nil
+	}
+	if !fio.FileExists(filepath.Join(path, "HEAD")) && !fio.FileExists(moving) {
+		return nil
+	}
 
+	slog.Info("moving repository",
+		slog.String("path", path),
+		slog.String("to", dot),
+	)
+	err := os.MkdirAll(moving, 0755)
+	if err != nil {
+		return err
+	}
+	for _, name := range gitFiles {
+		err = os.Rename(filepath.Join(path, name), filepath.Join(moving, name))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	err = os.Remove(dot)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return os.Rename(moving, dot)
+}
+
+func deleteRepo(path string) error {
 	return os.RemoveAll(path)
 }
 
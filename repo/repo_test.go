@@ -0,0 +1,125 @@
+package repo
+
+import (
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/cache"
+	"github.com/go-git/go-git/v5/storage/filesystem"
+	"github.com/google/uuid"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestInMemory(t *testing.T) {
+	dir := t.TempDir()
+
+	// Both repositories are opened in the same folder, which neither of them
+	// uses.
+	repos := make([]*Repository, 2)
+	for i := range repos {
+		r, err := Open(dir, testAuthor, InMemory())
+		if err != nil {
+			t.Fatalf("Failed to open repository: %v", err)
+		}
+		repos[i] = r
+	}
+
+	for i, r := range repos {
+		fs, err := r.Filesystem()
+		if err != nil {
+			t.Fatalf("Failed to open worktree: %v", err)
+		}
+		if err = util.WriteFile(fs, "tasks.md", []byte{byte('a' + i)}, 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+
+		change := Change{Action: ActionCreate, TaskID: uuid.New(), Title: "Task"}
+		if _, err = r.Commit(change, "tasks.md"); err != nil {
+			t.Fatalf("Failed to commit: %v", err)
+		}
+	}
+
+	t.Run("Repositories are independent", func(t *testing.T) {
+		for i, r := range repos {
+			log, err := r.Log()
+			if err != nil {
+				t.Fatalf("Failed to read log: %v", err)
+			}
+			if len(log) != 1 {
+				t.Fatalf("Expected 1 revision, got %d", len(log))
+			}
+
+			content, ok, err := r.ReadFile(log[0].Hash, "tasks.md")
+			if err != nil || !ok {
+				t.Fatalf("Failed to read file: %v", err)
+			}
+			if string(content) != string(rune('a'+i)) {
+				t.Fatalf("Expected %q, got %q", string(rune('a'+i)), content)
+			}
+		}
+	})
+
+	t.Run("Nothing on disk", func(t *testing.T) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatalf("Failed to read folder: %v", err)
+		}
+		if len(entries) != 0 {
+			t.Fatalf("Expected an empty folder, got %v", entries)
+		}
+	})
+
+	t.Run("Workspaces", func(t *testing.T) {
+		r := repos[0]
+		if _, err := r.CreateWorkspace("scratch", ""); err != nil {
+			t.Fatalf("Failed to create workspace: %v", err)
+		}
+		if err := r.SwitchWorkspace("scratch"); err != nil {
+			t.Fatalf("Failed to switch workspace: %v", err)
+		}
+	})
+}
+
+func TestMoveRepo(t *testing.T) {
+	dir := t.TempDir()
+
+	// Repositories used to keep their git files next to the worktree files.
+	fs := osfs.New(dir)
+	old, err := git.Init(filesystem.NewStorage(fs, cache.NewObjectLRUDefault()), fs)
+	if err != nil {
+		t.Fatalf("Failed to create repository: %v", err)
+	}
+	r := &Repository{repo: old, author: testAuthor}
+	if err = util.WriteFile(fs, "tasks.md", []byte("a"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	hash, err := r.Commit(Change{Action: ActionCreate, TaskID: uuid.New(), Title: "Task"}, "tasks.md")
+	if err != nil {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+
+	r, err = Open(dir, testAuthor)
+	if err != nil {
+		t.Fatalf("Failed to open repository: %v", err)
+	}
+	log, err := r.Log()
+	if err != nil {
+		t.Fatalf("Failed to read log: %v", err)
+	}
+	if len(log) != 1 || log[0].Hash != hash {
+		t.Fatalf("Expected the history to be kept, got %+v", log)
+	}
+	for _, name := range []string{"HEAD", "objects", "refs", movingFolder} {
+		if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Fatalf("Expected %s to be moved, got %v", name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, gitFolder, "HEAD")); err != nil {
+		t.Fatalf("Expected the repository in %s: %v", gitFolder, err)
+	}
+	if content, err := os.ReadFile(filepath.Join(dir, "tasks.md")); err != nil || string(content) != "a" {
+		t.Fatalf("Expected the worktree to be left alone, got %q: %v", content, err)
+	}
+}
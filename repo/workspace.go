@@ -0,0 +1,258 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"slices"
+	"strings"
+)
+
+var (
+	ErrWorkspaceExists    = errors.New("workspace already exists")
+	ErrWorkspaceNotFound  = errors.New("workspace not found")
+	ErrInvalidWorkspace   = errors.New("invalid workspace name")
+	ErrCurrentWorkspace   = errors.New("workspace is the current workspace")
+	ErrUncommittedChanges = errors.New("uncommitted changes")
+	ErrEmptyRepository    = errors.New("repository has no revisions")
+)
+
+// Workspace is a named backlog kept on its own branch, such as "sprint-42"
+// or "experiment".
+type Workspace struct {
+	Name    string
+	Head    plumbing.Hash
+	Current bool
+}
+
+func workspaceRef(name string) (plumbing.ReferenceName, error) {
+	ref := plumbing.NewBranchReferenceName(name)
+	if name == "" || strings.ContainsAny(name, " \t") || ref.Validate() != nil {
+		return "", fmt.Errorf("%w: %q", ErrInvalidWorkspace, name)
+	}
+	return ref, nil
+}
+
+// Workspace returns the name of the current workspace.
+func (r *Repository) Workspace() (string, error) {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+
+	branch, err := r.branch()
+	if err != nil {
+		return "", err
+	}
+	return branch.Short(), nil
+}
+
+// Workspaces returns all workspaces sorted by name. A repository without
+// revisions only has the current workspace.
+func (r *Repository) Workspaces() ([]Workspace, error) {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+
+	current, err := r.branch()
+	if err != nil {
+		return nil, err
+	}
+
+	iter, err := r.repo.Branches()
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	var workspaces []Workspace
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		workspaces = append(workspaces, Workspace{
+			Name:    ref.Name().Short(),
+			Head:    ref.Hash(),
+			Current: ref.Name() == current,
+		})
+		return nil
+	})
+	if err != nil && !errors.Is(err, storer.ErrStop) {
+		return nil, err
+	}
+
+	if !slices.ContainsFunc(workspaces, func(w Workspace) bool { return w.Current }) {
+		workspaces = append(workspaces, Workspace{Name: current.Short(), Current: true})
+	}
+	slices.SortFunc(workspaces, func(a, b Workspace) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return workspaces, nil
+}
+
+// CreateWorkspace creates a workspace that starts at the revision from, or at
+// the current revision if from is empty. It does not switch to the workspace.
+func (r *Repository) CreateWorkspace(name, from string) (Workspace, error) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	ref, err := workspaceRef(name)
+	if err != nil {
+		return Workspace{}, err
+	}
+	if _, err = r.repo.Reference(ref, false); err == nil {
+		return Workspace{}, fmt.Errorf("%w: %s", ErrWorkspaceExists, name)
+	}
+
+	if from == "" {
+		from = string(plumbing.HEAD)
+	}
+	hash, err := r.repo.ResolveRevision(plumbing.Revision(from))
+	if from == string(plumbing.HEAD) && err != nil {
+		return Workspace{}, fmt.Errorf("unable to create workspace %s: %w", name, ErrEmptyRepository)
+	}
+	if err != nil {
+		return Workspace{}, fmt.Errorf("%w: %s", ErrRevisionNotFound, from)
+	}
+
+	err = r.repo.Storer.SetReference(plumbing.NewHashReference(ref, *hash))
+	if err != nil {
+		return Workspace{}, err
+	}
+	return Workspace{Name: name, Head: *hash}, nil
+}
+
+// SwitchWorkspace checks out the workspace. It refuses to switch while files
+// in the repository have changes that are not committed, those would be lost.
+func (r *Repository) SwitchWorkspace(name string) error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	ref, err := r.workspace(name)
+	if err != nil {
+		return err
+	}
+
+	w, err := getWorktree(r.repo)
+	if err != nil {
+		return err
+	}
+
+	status, err := w.Status()
+	if err != nil {
+		return err
+	}
+	var dirty []string
+	for path, s := range status {
+		if s.Worktree != git.Unmodified && s.Worktree != git.Untracked || s.Staging != git.Unmodified && s.Staging != git.Untracked {
+			dirty = append(dirty, path)
+		}
+	}
+	if len(dirty) > 0 {
+		slices.Sort(dirty)
+		return fmt.Errorf("unable to switch to %s: %w in %s", name, ErrUncommittedChanges, strings.Join(dirty, ", "))
+	}
+
+	// The worktree is known to be clean, forcing the checkout only makes sure
+	// HEAD is not left pointing at the new branch if the checkout fails.
+	return w.Checkout(&git.CheckoutOptions{Branch: ref.Name(), Force: true})
+}
+
+// MergeWorkspace merges the workspace into the current workspace. See Pull
+// for how files changed in both workspaces are merged.
+func (r *Repository) MergeWorkspace(name string) (MergeResult, error) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	ref, err := r.workspace(name)
+	if err != nil {
+		return MergeResult{}, err
+	}
+
+	current, err := r.branch()
+	if err != nil {
+		return MergeResult{}, err
+	}
+	if current == ref.Name() {
+		return MergeResult{}, fmt.Errorf("unable to merge %s into itself: %w", name, ErrCurrentWorkspace)
+	}
+
+	return r.merge(ref.Hash(), Change{Action: ActionMerge, Title: name})
+}
+
+// DeleteWorkspace deletes a workspace other than the current one. Revisions
+// that were merged into another workspace are kept there.
+func (r *Repository) DeleteWorkspace(name string) error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	ref, err := r.workspace(name)
+	if err != nil {
+		return err
+	}
+
+	current, err := r.branch()
+	if err != nil {
+		return err
+	}
+	if current == ref.Name() {
+		return fmt.Errorf("unable to delete %s: %w", name, ErrCurrentWorkspace)
+	}
+
+	return r.repo.Storer.RemoveReference(ref.Name())
+}
+
+// Discard replaces the given paths with their contents in the current
+// revision, throwing away uncommitted changes. Paths that are not in the
+// current revision are left alone.
+func (r *Repository) Discard(paths ...string) error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	head, err := r.repo.Head()
+	if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	c, err := r.repo.CommitObject(head.Hash())
+	if err != nil {
+		return err
+	}
+	files, err := treeFiles(c)
+	if err != nil {
+		return err
+	}
+
+	w, err := getWorktree(r.repo)
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		hash, ok := files[path]
+		if !ok {
+			continue
+		}
+		content, err := r.readBlob(hash)
+		if err != nil {
+			return err
+		}
+		err = util.WriteFile(w.Filesystem, path, content, 0644)
+		if err != nil {
+			return fmt.Errorf("unable to restore %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+func (r *Repository) workspace(name string) (*plumbing.Reference, error) {
+	name = strings.TrimSpace(name)
+	refName, err := workspaceRef(name)
+	if err != nil {
+		return nil, err
+	}
+
+	ref, err := r.repo.Reference(refName, true)
+	if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return nil, fmt.Errorf("%w: %s", ErrWorkspaceNotFound, name)
+	}
+	return ref, err
+}
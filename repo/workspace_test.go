@@ -0,0 +1,170 @@
+package repo
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWorkspaces(t *testing.T) {
+	dir := t.TempDir()
+	r, err := Open(dir, testAuthor)
+	if err != nil {
+		t.Fatalf("Failed to open repository: %v", err)
+	}
+
+	t.Run("Create in empty repository", func(t *testing.T) {
+		if _, err := r.CreateWorkspace("sprint-42", ""); !errors.Is(err, ErrEmptyRepository) {
+			t.Fatalf("Expected error %v, got %v", ErrEmptyRepository, err)
+		}
+	})
+
+	writeAndCommit(t, r, "tasks.md", "main\n")
+	main, err := r.Workspace()
+	if err != nil {
+		t.Fatalf("Failed to read workspace: %v", err)
+	}
+
+	t.Run("Create", func(t *testing.T) {
+		if _, err := r.CreateWorkspace("sprint-42", ""); err != nil {
+			t.Fatalf("Failed to create workspace: %v", err)
+		}
+		if _, err := r.CreateWorkspace("sprint-42", ""); !errors.Is(err, ErrWorkspaceExists) {
+			t.Fatalf("Expected error %v, got %v", ErrWorkspaceExists, err)
+		}
+		if _, err := r.CreateWorkspace("bad name", ""); !errors.Is(err, ErrInvalidWorkspace) {
+			t.Fatalf("Expected error %v, got %v", ErrInvalidWorkspace, err)
+		}
+
+		workspaces, err := r.Workspaces()
+		if err != nil {
+			t.Fatalf("Failed to list workspaces: %v", err)
+		}
+		if len(workspaces) != 2 {
+			t.Fatalf("Expected 2 workspaces, got %+v", workspaces)
+		}
+		for _, w := range workspaces {
+			if w.Current != (w.Name == main) {
+				t.Fatalf("Expected %s to be the current workspace, got %+v", main, workspaces)
+			}
+		}
+	})
+
+	t.Run("Switch", func(t *testing.T) {
+		if err := r.SwitchWorkspace("sprint-42"); err != nil {
+			t.Fatalf("Failed to switch workspace: %v", err)
+		}
+		writeAndCommit(t, r, "tasks.md", "sprint\n")
+
+		if err := r.SwitchWorkspace(main); err != nil {
+			t.Fatalf("Failed to switch workspace: %v", err)
+		}
+		if got := readWorktree(t, r, "tasks.md"); got != "main\n" {
+			t.Fatalf("Expected %q, got %q", "main\n", got)
+		}
+		if current, _ := r.Workspace(); current != main {
+			t.Fatalf("Expected workspace %s, got %s", main, current)
+		}
+	})
+
+	t.Run("Refuse to switch with uncommitted changes", func(t *testing.T) {
+		path := filepath.Join(dir, "tasks.md")
+		if err := os.WriteFile(path, []byte("dirty\n"), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+
+		if err := r.SwitchWorkspace("sprint-42"); !errors.Is(err, ErrUncommittedChanges) {
+			t.Fatalf("Expected error %v, got %v", ErrUncommittedChanges, err)
+		}
+
+		if err := r.Discard("tasks.md"); err != nil {
+			t.Fatalf("Failed to discard changes: %v", err)
+		}
+		if got := readWorktree(t, r, "tasks.md"); got != "main\n" {
+			t.Fatalf("Expected %q, got %q", "main\n", got)
+		}
+	})
+
+	t.Run("Merge", func(t *testing.T) {
+		if _, err := r.MergeWorkspace(main); !errors.Is(err, ErrCurrentWorkspace) {
+			t.Fatalf("Expected error %v, got %v", ErrCurrentWorkspace, err)
+		}
+
+		result, err := r.MergeWorkspace("sprint-42")
+		if err != nil {
+			t.Fatalf("Failed to merge workspace: %v", err)
+		}
+		if result.Status != FastForward {
+			t.Fatalf("Expected status %d, got %d", FastForward, result.Status)
+		}
+		if got := readWorktree(t, r, "tasks.md"); got != "sprint\n" {
+			t.Fatalf("Expected %q, got %q", "sprint\n", got)
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		if err := r.DeleteWorkspace(main); !errors.Is(err, ErrCurrentWorkspace) {
+			t.Fatalf("Expected error %v, got %v", ErrCurrentWorkspace, err)
+		}
+		if err := r.DeleteWorkspace("sprint-42"); err != nil {
+			t.Fatalf("Failed to delete workspace: %v", err)
+		}
+		if err := r.SwitchWorkspace("sprint-42"); !errors.Is(err, ErrWorkspaceNotFound) {
+			t.Fatalf("Expected error %v, got %v", ErrWorkspaceNotFound, err)
+		}
+	})
+}
+
+func TestMergeKey(t *testing.T) {
+	dir := t.TempDir()
+	r, err := Open(dir, testAuthor)
+	if err != nil {
+		t.Fatalf("Failed to open repository: %v", err)
+	}
+	if err = os.Mkdir(filepath.Join(dir, "tasks"), 0755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+	writeAndCommit(t, r, "tasks/old-1.md", "task\n")
+	main, err := r.Workspace()
+	if err != nil {
+		t.Fatalf("Failed to read workspace: %v", err)
+	}
+
+	if _, err = r.CreateWorkspace("rename", ""); err != nil {
+		t.Fatalf("Failed to create workspace: %v", err)
+	}
+	if err = r.SwitchWorkspace("rename"); err != nil {
+		t.Fatalf("Failed to switch workspace: %v", err)
+	}
+	if err = os.Rename(filepath.Join(dir, "tasks/old-1.md"), filepath.Join(dir, "tasks/new-1.md")); err != nil {
+		t.Fatalf("Failed to rename file: %v", err)
+	}
+	if _, err = r.Commit(Change{Action: ActionUpdate, Title: "rename"}, "tasks"); err != nil {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+
+	if err = r.SwitchWorkspace(main); err != nil {
+		t.Fatalf("Failed to switch workspace: %v", err)
+	}
+	writeAndCommit(t, r, "tasks/old-1.md", "changed task\n")
+
+	r.SetMergeKey(func(path string) string {
+		return path[strings.LastIndex(path, "-")+1:]
+	})
+	result, err := r.MergeWorkspace("rename")
+	if err != nil {
+		t.Fatalf("Failed to merge workspace: %v", err)
+	}
+	if result.Status != Merged {
+		t.Fatalf("Expected status %d, got %d", Merged, result.Status)
+	}
+
+	if got := readWorktree(t, r, "tasks/new-1.md"); got != "changed task\n" {
+		t.Fatalf("Expected %q, got %q", "changed task\n", got)
+	}
+	if _, err = os.Stat(filepath.Join(dir, "tasks/old-1.md")); !os.IsNotExist(err) {
+		t.Fatalf("Expected the old name to be removed, got %v", err)
+	}
+}
@@ -0,0 +1,70 @@
+package task
+
+import (
+	"github.com/google/uuid"
+	"strings"
+	"time"
+)
+
+// FieldChange is the change of a single field between two versions of a task.
+type FieldChange struct {
+	Field string
+	Old   string
+	New   string
+}
+
+// Diff returns the fields that differ between two versions of a task. A nil
+// version stands for a task that does not exist, so every field of the other
+// version is reported.
+func Diff(old, new *Task) []FieldChange {
+	oldFields, newFields := fields(old), fields(new)
+
+	var changes []FieldChange
+	for i := range newFields {
+		if oldFields[i].value != newFields[i].value {
+			changes = append(changes, FieldChange{
+				Field: newFields[i].name,
+				Old:   oldFields[i].value,
+				New:   newFields[i].value,
+			})
+		}
+	}
+	return changes
+}
+
+type field struct {
+	name  string
+	value string
+}
+
+func fields(t *Task) []field {
+	if t == nil {
+		t = &Task{}
+	}
+
+	return []field{
+		{name: "ID", value: id(t)},
+		{name: "Title", value: t.Title},
+		{name: "Status", value: string(t.Status)},
+		{name: "Tags", value: strings.Join(t.Tags, ", ")},
+		{name: "Priority", value: string(t.Priority)},
+		{name: "Due", value: FormatDue(t.Due)},
+		{name: "Created", value: timestamp(t.Created)},
+		{name: "Modified", value: timestamp(t.Modified)},
+		{name: "Description", value: t.Description},
+	}
+}
+
+func id(t *Task) string {
+	if t.ID == uuid.Nil {
+		return ""
+	}
+	return t.ID.String()
+}
+
+func timestamp(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
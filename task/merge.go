@@ -0,0 +1,171 @@
+package task
+
+import (
+	"github.com/google/uuid"
+	"slices"
+)
+
+const (
+	// FieldTask is the field of a conflict between a task deleted on one side
+	// of a merge and modified on the other. Its values are Deleted or Modified.
+	FieldTask = "Task"
+
+	Deleted  = "deleted"
+	Modified = "modified"
+)
+
+// Conflict is a field of a task that both sides of a merge changed to
+// different values.
+type Conflict struct {
+	TaskID uuid.UUID
+	Field  string
+	Base   string
+	Ours   string
+	Theirs string
+}
+
+// Merge combines the changes ours and theirs made to base, field by field.
+// A field changed on one side only takes the changed value, and tags added or
+// removed on either side are added or removed. A field changed on both sides
+// to different values keeps our value and is reported as a conflict. A nil
+// base stands for a task both sides added.
+func Merge(base, ours, theirs *Task) (*Task, []Conflict) {
+	if base == nil {
+		base = &Task{ID: ours.ID}
+	}
+
+	merged := *ours
+	merged.Tags = mergeTags(base.Tags, ours.Tags, theirs.Tags)
+	if theirs.Modified.After(merged.Modified) {
+		merged.Modified = theirs.Modified
+	}
+
+	var conflicts []Conflict
+	conflict := func(field, b, o, t string) {
+		conflicts = append(conflicts, Conflict{TaskID: ours.ID, Field: field, Base: b, Ours: o, Theirs: t})
+	}
+
+	if title, ok := mergeValue(base.Title, ours.Title, theirs.Title); ok {
+		merged.Title = title
+	} else {
+		conflict("Title", base.Title, ours.Title, theirs.Title)
+	}
+	if description, ok := mergeValue(base.Description, ours.Description, theirs.Description); ok {
+		merged.Description = description
+	} else {
+		conflict("Description", base.Description, ours.Description, theirs.Description)
+	}
+	if status, ok := mergeValue(base.Status, ours.Status, theirs.Status); ok {
+		merged.Status = status
+	} else {
+		conflict("Status", string(base.Status), string(ours.Status), string(theirs.Status))
+	}
+	if priority, ok := mergeValue(base.Priority, ours.Priority, theirs.Priority); ok {
+		merged.Priority = priority
+	} else {
+		conflict("Priority", string(base.Priority), string(ours.Priority), string(theirs.Priority))
+	}
+	if due, ok := mergeValue(FormatDue(base.Due), FormatDue(ours.Due), FormatDue(theirs.Due)); ok {
+		// The dates were parsed from the tasks, so they parse again.
+		merged.Due, _ = ParseDue(due)
+	} else {
+		conflict("Due", FormatDue(base.Due), FormatDue(ours.Due), FormatDue(theirs.Due))
+	}
+
+	return &merged, conflicts
+}
+
+// MergeList merges two lists of tasks that both descend from base. Tasks are
+// merged with Merge, tasks added on either side are kept and tasks deleted on
+// one side are dropped unless the other side modified them, which is reported
+// as a conflict on FieldTask while the task is kept.
+//
+// The order of ours is kept; a task added by theirs is placed after the task
+// that preceded it in theirs.
+func MergeList(base, ours, theirs []*Task) ([]*Task, []Conflict) {
+	b, o, t := byID(base), byID(ours), byID(theirs)
+
+	var conflicts []Conflict
+	merged := make([]*Task, 0, len(ours))
+
+	for _, ot := range ours {
+		bt, tt := b[ot.ID], t[ot.ID]
+		switch {
+		case tt != nil:
+			m, c := Merge(bt, ot, tt)
+			merged = append(merged, m)
+			conflicts = append(conflicts, c...)
+		case bt == nil:
+			// Added by us.
+			merged = append(merged, ot)
+		case !ot.Equal(bt):
+			merged = append(merged, ot)
+			conflicts = append(conflicts, Conflict{TaskID: ot.ID, Field: FieldTask, Ours: Modified, Theirs: Deleted})
+		}
+		// Otherwise they deleted a task we did not touch.
+	}
+
+	for i, tt := range theirs {
+		if o[tt.ID] != nil {
+			continue
+		}
+
+		bt := b[tt.ID]
+		switch {
+		case bt == nil:
+		case !tt.Equal(bt):
+			conflicts = append(conflicts, Conflict{TaskID: tt.ID, Field: FieldTask, Ours: Deleted, Theirs: Modified})
+		default:
+			// We deleted a task they did not touch.
+			continue
+		}
+
+		at := 0
+		for j := i - 1; j >= 0; j-- {
+			if k := slices.IndexFunc(merged, func(m *Task) bool { return m.ID == theirs[j].ID }); k >= 0 {
+				at = k + 1
+				break
+			}
+		}
+		merged = slices.Insert(merged, at, tt)
+	}
+
+	return merged, conflicts
+}
+
+// mergeValue returns the merged value and whether the sides agree.
+func mergeValue[T comparable](base, ours, theirs T) (T, bool) {
+	switch {
+	case ours == theirs, base == theirs:
+		return ours, true
+	case base == ours:
+		return theirs, true
+	default:
+		return ours, false
+	}
+}
+
+// mergeTags keeps the tags both sides kept and the tags either side added.
+func mergeTags(base, ours, theirs []string) []string {
+	var merged []string
+	for _, tag := range slices.Concat(ours, theirs) {
+		if slices.Contains(merged, tag) {
+			continue
+		}
+
+		// A tag from base that is missing on either side was removed there.
+		if slices.Contains(base, tag) && !(slices.Contains(ours, tag) && slices.Contains(theirs, tag)) {
+			continue
+		}
+		merged = append(merged, tag)
+	}
+	return merged
+}
+
+func byID(tasks []*Task) map[uuid.UUID]*Task {
+	m := make(map[uuid.UUID]*Task, len(tasks))
+	for _, t := range tasks {
+		m[t.ID] = t
+	}
+	return m
+}
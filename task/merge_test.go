@@ -0,0 +1,149 @@
+package task
+
+import (
+	"github.com/google/uuid"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestMerge(t *testing.T) {
+	ts := time.Date(2024, 10, 1, 12, 0, 0, 0, time.UTC)
+	base := &Task{ID: uuid.New(), Title: "Title", Status: StatusTodo, Tags: []string{"a", "b"}, Created: ts, Modified: ts, Description: "Description"}
+
+	tests := []struct {
+		name      string
+		ours      func(t *Task)
+		theirs    func(t *Task)
+		expected  func(t *Task)
+		conflicts []string
+	}{
+		{
+			name:     "Different fields",
+			ours:     func(t *Task) { t.Title = "Ours" },
+			theirs:   func(t *Task) { t.Status = StatusDone },
+			expected: func(t *Task) { t.Title = "Ours"; t.Status = StatusDone },
+		},
+		{
+			name:     "Same change",
+			ours:     func(t *Task) { t.Description = "Same" },
+			theirs:   func(t *Task) { t.Description = "Same" },
+			expected: func(t *Task) { t.Description = "Same" },
+		},
+		{
+			name:     "Tags",
+			ours:     func(t *Task) { t.Tags = []string{"a", "c"} },
+			theirs:   func(t *Task) { t.Tags = []string{"b", "a", "d"} },
+			expected: func(t *Task) { t.Tags = []string{"a", "c", "d"} },
+		},
+		{
+			name:      "Conflicting title",
+			ours:      func(t *Task) { t.Title = "Ours"; t.Description = "Ours" },
+			theirs:    func(t *Task) { t.Title = "Theirs" },
+			expected:  func(t *Task) { t.Title = "Ours"; t.Description = "Ours" },
+			conflicts: []string{"Title"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ours, theirs, expected := *base, *base, *base
+			ours.Modified = ts.Add(time.Hour)
+			theirs.Modified = ts.Add(2 * time.Hour)
+			expected.Modified = theirs.Modified
+			test.ours(&ours)
+			test.theirs(&theirs)
+			test.expected(&expected)
+
+			merged, conflicts := Merge(base, &ours, &theirs)
+			if !merged.Equal(&expected) {
+				t.Fatalf("Expected %+v, got %+v", expected, merged)
+			}
+
+			var fields []string
+			for _, c := range conflicts {
+				fields = append(fields, c.Field)
+			}
+			if !slices.Equal(fields, test.conflicts) {
+				t.Fatalf("Expected conflicts in %v, got %+v", test.conflicts, conflicts)
+			}
+		})
+	}
+
+	t.Run("Conflict values", func(t *testing.T) {
+		ours, theirs := *base, *base
+		ours.Status, theirs.Status = StatusDone, "blocked"
+
+		_, conflicts := Merge(base, &ours, &theirs)
+		expected := Conflict{TaskID: base.ID, Field: "Status", Base: "todo", Ours: "done", Theirs: "blocked"}
+		if len(conflicts) != 1 || conflicts[0] != expected {
+			t.Fatalf("Expected conflict %+v, got %+v", expected, conflicts)
+		}
+	})
+}
+
+func TestMergeList(t *testing.T) {
+	ts := time.Date(2024, 10, 1, 12, 0, 0, 0, time.UTC)
+	newTask := func(title string) *Task {
+		return &Task{ID: uuid.New(), Title: title, Status: StatusTodo, Created: ts, Modified: ts}
+	}
+	modified := func(t *Task) *Task {
+		c := *t
+		c.Description = "Modified"
+		return &c
+	}
+	titles := func(tasks []*Task) []string {
+		var s []string
+		for _, t := range tasks {
+			s = append(s, t.Title)
+		}
+		return s
+	}
+
+	a, b, c := newTask("a"), newTask("b"), newTask("c")
+	base := []*Task{a, b, c}
+
+	t.Run("Additions", func(t *testing.T) {
+		ours := []*Task{a, b, c, newTask("ours")}
+		theirs := []*Task{a, newTask("theirs"), b, c}
+
+		merged, conflicts := MergeList(base, ours, theirs)
+		if len(conflicts) != 0 {
+			t.Fatalf("Expected no conflicts, got %+v", conflicts)
+		}
+		expected := []string{"a", "theirs", "b", "c", "ours"}
+		if !slices.Equal(titles(merged), expected) {
+			t.Fatalf("Expected order %v, got %v", expected, titles(merged))
+		}
+	})
+
+	t.Run("Deletions", func(t *testing.T) {
+		ours := []*Task{a, c}
+		theirs := []*Task{b, c}
+
+		merged, conflicts := MergeList(base, ours, theirs)
+		if len(conflicts) != 0 {
+			t.Fatalf("Expected no conflicts, got %+v", conflicts)
+		}
+		if !slices.Equal(titles(merged), []string{"c"}) {
+			t.Fatalf("Expected only c, got %v", titles(merged))
+		}
+	})
+
+	t.Run("Delete and modify", func(t *testing.T) {
+		ours := []*Task{a, c}
+		theirs := []*Task{a, modified(b), c}
+
+		merged, conflicts := MergeList(base, ours, theirs)
+		expected := Conflict{TaskID: b.ID, Field: FieldTask, Ours: Deleted, Theirs: Modified}
+		if len(conflicts) != 1 || conflicts[0] != expected {
+			t.Fatalf("Expected conflict %+v, got %+v", expected, conflicts)
+		}
+		if !slices.Equal(titles(merged), []string{"a", "b", "c"}) {
+			t.Fatalf("Expected the modified task to be kept, got %v", titles(merged))
+		}
+		if merged[1].Description != "Modified" {
+			t.Fatalf("Expected their version of the task, got %+v", merged[1])
+		}
+	})
+}
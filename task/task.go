@@ -0,0 +1,331 @@
+package task
+
+import (
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	maxTitleLength = 200
+)
+
+var (
+	ErrMissingID    = errors.New("task has no id")
+	ErrEmptyTitle   = errors.New("task title is empty")
+	ErrTitleTooLong = fmt.Errorf("task title exceeds %d characters", maxTitleLength)
+	ErrTitleNewline = errors.New("task title contains a line break")
+	ErrTimestamps   = errors.New("task modified before it was created")
+	ErrStatus       = errors.New("unknown task status")
+	ErrTag          = errors.New("invalid task tag")
+	ErrPriority     = errors.New("unknown task priority")
+	ErrDue          = errors.New("invalid task due date")
+)
+
+type Status string
+
+const (
+	StatusTodo Status = "todo"
+	StatusDone Status = "done"
+)
+
+// Statuses lists every status in the order a task goes through them.
+var Statuses = []Status{StatusTodo, StatusDone}
+
+func (s Status) Valid() bool {
+	switch s {
+	case StatusTodo, StatusDone:
+		return true
+	default:
+		return false
+	}
+}
+
+// Priority tells how urgent a task is. A task without a priority has the
+// empty priority.
+type Priority string
+
+const (
+	PriorityNone   Priority = ""
+	PriorityLow    Priority = "low"
+	PriorityMedium Priority = "medium"
+	PriorityHigh   Priority = "high"
+)
+
+// Priorities lists the priorities from the least to the most urgent.
+var Priorities = []Priority{PriorityNone, PriorityLow, PriorityMedium, PriorityHigh}
+
+func (p Priority) Valid() bool {
+	return slices.Contains(Priorities, p)
+}
+
+// DateLayout is the layout of due dates, which have no time of day.
+const DateLayout = time.DateOnly
+
+// ParseDue parses a due date in DateLayout. The empty string is the zero time,
+// which means the task has no due date.
+func ParseDue(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, nil
+	}
+	due, err := time.Parse(DateLayout, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %q", ErrDue, s)
+	}
+	return due, nil
+}
+
+// FormatDue formats a due date in DateLayout, the zero time as the empty
+// string.
+func FormatDue(due time.Time) string {
+	if due.IsZero() {
+		return ""
+	}
+	return due.Format(DateLayout)
+}
+
+// now returns the current time used for the Created and Modified timestamps.
+// Timestamps are kept in UTC with second precision so they survive a round
+// trip through every serialized format unchanged.
+var now = func() time.Time {
+	return time.Now().UTC().Truncate(time.Second)
+}
+
+type Task struct {
+	ID          uuid.UUID
+	Title       string
+	Status      Status
+	Tags        []string
+	Priority    Priority
+	Due         time.Time
+	Created     time.Time
+	Modified    time.Time
+	Description string
+}
+
+// New returns a Task with a fresh ID and both timestamps set to the current time.
+func New(title, description string) (*Task, error) {
+	title = normalizeTitle(title)
+	if err := validateTitle(title); err != nil {
+		return nil, err
+	}
+
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return nil, fmt.Errorf("unable to generate task id: %w", err)
+	}
+
+	t := now()
+	return &Task{
+		ID:          id,
+		Title:       title,
+		Status:      StatusTodo,
+		Created:     t,
+		Modified:    t,
+		Description: normalizeDescription(description),
+	}, nil
+}
+
+// Update replaces the title and description of the task and marks it modified.
+// The task is left untouched if the new title is invalid.
+func (t *Task) Update(title, description string) error {
+	title = normalizeTitle(title)
+	if err := validateTitle(title); err != nil {
+		return err
+	}
+
+	t.Title = title
+	t.Description = normalizeDescription(description)
+	t.Touch()
+	return nil
+}
+
+func (t *Task) SetTitle(title string) error {
+	return t.Update(title, t.Description)
+}
+
+func (t *Task) SetDescription(description string) {
+	t.Description = normalizeDescription(description)
+	t.Touch()
+}
+
+func (t *Task) SetStatus(status Status) error {
+	if !status.Valid() {
+		return fmt.Errorf("%w: %q", ErrStatus, status)
+	}
+	if t.Status != status {
+		t.Status = status
+		t.Touch()
+	}
+	return nil
+}
+
+func (t *Task) SetPriority(priority Priority) error {
+	if !priority.Valid() {
+		return fmt.Errorf("%w: %q", ErrPriority, priority)
+	}
+	if t.Priority != priority {
+		t.Priority = priority
+		t.Touch()
+	}
+	return nil
+}
+
+// SetDue sets the due date of the task; the zero time removes it. Only the
+// date is kept.
+func (t *Task) SetDue(due time.Time) {
+	due = normalizeDue(due)
+	if !t.Due.Equal(due) {
+		t.Due = due
+		t.Touch()
+	}
+}
+
+// SetTags replaces the tags of the task. Tags are trimmed and duplicates are
+// dropped, keeping the first occurrence.
+func (t *Task) SetTags(tags []string) error {
+	tags = normalizeTags(tags)
+	if err := validateTags(tags); err != nil {
+		return err
+	}
+	if !slices.Equal(t.Tags, tags) {
+		t.Tags = tags
+		t.Touch()
+	}
+	return nil
+}
+
+// HasTag reports whether the task is tagged with tag.
+func (t *Task) HasTag(tag string) bool {
+	return slices.Contains(t.Tags, strings.TrimSpace(tag))
+}
+
+func (t *Task) Done() bool {
+	return t.Status == StatusDone
+}
+
+// Touch sets the modified timestamp to the current time.
+func (t *Task) Touch() {
+	t.Modified = now()
+	if t.Modified.Before(t.Created) {
+		t.Modified = t.Created
+	}
+}
+
+// Normalize brings a decoded task in the form the setters keep it in. Tasks
+// stored before a field was normalized may differ from it.
+func (t *Task) Normalize() {
+	t.Description = normalizeDescription(t.Description)
+}
+
+func (t *Task) Validate() error {
+	if t.ID == uuid.Nil {
+		return ErrMissingID
+	}
+	if err := validateTitle(t.Title); err != nil {
+		return err
+	}
+	if !t.Status.Valid() {
+		return fmt.Errorf("%w: %q", ErrStatus, t.Status)
+	}
+	if err := validateTags(t.Tags); err != nil {
+		return err
+	}
+	if !t.Priority.Valid() {
+		return fmt.Errorf("%w: %q", ErrPriority, t.Priority)
+	}
+	if !t.Due.Equal(normalizeDue(t.Due)) {
+		return fmt.Errorf("%w: %s is not a date", ErrDue, t.Due)
+	}
+	if t.Modified.Before(t.Created) {
+		return ErrTimestamps
+	}
+	return nil
+}
+
+// Equal reports whether both tasks hold the same data.
+func (t *Task) Equal(other *Task) bool {
+	if t == nil || other == nil {
+		return t == other
+	}
+	return t.ID == other.ID &&
+		t.Title == other.Title &&
+		t.Status == other.Status &&
+		slices.Equal(t.Tags, other.Tags) &&
+		t.Priority == other.Priority &&
+		t.Due.Equal(other.Due) &&
+		t.Created.Equal(other.Created) &&
+		t.Modified.Equal(other.Modified) &&
+		t.Description == other.Description
+}
+
+func (t *Task) String() string {
+	return fmt.Sprintf("[%s]: %s", t.ID, t.Title)
+}
+
+func normalizeTitle(title string) string {
+	return strings.TrimSpace(title)
+}
+
+// normalizeDescription trims the description and ends its lines with \n.
+// Browsers send text areas with \r\n line endings.
+func normalizeDescription(description string) string {
+	description = strings.ReplaceAll(description, "\r\n", "\n")
+	description = strings.ReplaceAll(description, "\r", "\n")
+	return strings.TrimSpace(description)
+}
+
+// normalizeDue keeps the date of due, at midnight UTC.
+func normalizeDue(due time.Time) time.Time {
+	if due.IsZero() {
+		return time.Time{}
+	}
+	y, m, d := due.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// ParseTags splits a comma separated list of tags.
+func ParseTags(s string) []string {
+	return normalizeTags(strings.Split(s, ","))
+}
+
+func normalizeTags(tags []string) []string {
+	var normalized []string
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag != "" && !slices.Contains(normalized, tag) {
+			normalized = append(normalized, tag)
+		}
+	}
+	return normalized
+}
+
+func validateTags(tags []string) error {
+	for i, tag := range tags {
+		if tag == "" || tag != strings.TrimSpace(tag) || strings.ContainsAny(tag, ",\r\n") {
+			return fmt.Errorf("%w: %q", ErrTag, tag)
+		}
+		if slices.Contains(tags[:i], tag) {
+			return fmt.Errorf("%w: %q is listed twice", ErrTag, tag)
+		}
+	}
+	return nil
+}
+
+func validateTitle(title string) error {
+	if title == "" {
+		return ErrEmptyTitle
+	}
+	if utf8.RuneCountInString(title) > maxTitleLength {
+		return ErrTitleTooLong
+	}
+	if strings.ContainsAny(title, "\r\n") {
+		return ErrTitleNewline
+	}
+	return nil
+}
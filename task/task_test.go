@@ -0,0 +1,245 @@
+package task
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"strings"
+	"testing"
+	"time"
+)
+
+func fixedClock(t *testing.T, times ...time.Time) {
+	t.Helper()
+
+	original := now
+	t.Cleanup(func() { now = original })
+
+	i := 0
+	now = func() time.Time {
+		ts := times[i]
+		if i < len(times)-1 {
+			i++
+		}
+		return ts
+	}
+}
+
+func TestNew(t *testing.T) {
+	created := time.Date(2024, 10, 1, 12, 0, 0, 0, time.UTC)
+	fixedClock(t, created)
+
+	t.Run("Valid task", func(t *testing.T) {
+		task, err := New("  Write docs  ", "\nSome description\n")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if task.ID == uuid.Nil {
+			t.Fatalf("expected an id, got %s", task.ID)
+		}
+		if task.Title != "Write docs" {
+			t.Fatalf("expected trimmed title, got %q", task.Title)
+		}
+		if task.Description != "Some description" {
+			t.Fatalf("expected trimmed description, got %q", task.Description)
+		}
+		if !task.Created.Equal(created) || !task.Modified.Equal(created) {
+			t.Fatalf("expected timestamps %v, got %v and %v", created, task.Created, task.Modified)
+		}
+	})
+
+	t.Run("Line endings", func(t *testing.T) {
+		task, err := New("Title", "First\r\nSecond\rThird\r\n")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if task.Description != "First\nSecond\nThird" {
+			t.Fatalf("expected \\n line endings, got %q", task.Description)
+		}
+	})
+
+	t.Run("Unique ids", func(t *testing.T) {
+		a, _ := New("a", "")
+		b, _ := New("b", "")
+		if a.ID == b.ID {
+			t.Fatalf("expected unique ids, got %s twice", a.ID)
+		}
+	})
+
+	tests := []struct {
+		name          string
+		title         string
+		expectedError error
+	}{
+		{name: "Empty title", title: "", expectedError: ErrEmptyTitle},
+		{name: "Blank title", title: " \t ", expectedError: ErrEmptyTitle},
+		{name: "Title with newline", title: "one\ntwo", expectedError: ErrTitleNewline},
+		{name: "Title too long", title: strings.Repeat("x", maxTitleLength+1), expectedError: ErrTitleTooLong},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := New(test.title, "")
+			if !errors.Is(err, test.expectedError) {
+				t.Fatalf("expected error %v, got %v", test.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	created := time.Date(2024, 10, 1, 12, 0, 0, 0, time.UTC)
+	modified := created.Add(time.Hour)
+	fixedClock(t, created, modified)
+
+	task, err := New("Title", "Description")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	t.Run("Invalid update leaves task untouched", func(t *testing.T) {
+		if err := task.Update("", "changed"); !errors.Is(err, ErrEmptyTitle) {
+			t.Fatalf("expected error %v, got %v", ErrEmptyTitle, err)
+		}
+		if task.Title != "Title" || task.Description != "Description" || !task.Modified.Equal(created) {
+			t.Fatalf("expected task to be unchanged, got %+v", task)
+		}
+	})
+
+	t.Run("Valid update", func(t *testing.T) {
+		if err := task.Update("New title", "New description"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if task.Title != "New title" || task.Description != "New description" {
+			t.Fatalf("expected updated fields, got %+v", task)
+		}
+		if !task.Created.Equal(created) {
+			t.Fatalf("expected created %v, got %v", created, task.Created)
+		}
+		if !task.Modified.Equal(modified) {
+			t.Fatalf("expected modified %v, got %v", modified, task.Modified)
+		}
+	})
+}
+
+func TestSetTags(t *testing.T) {
+	task, err := New("Title", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err = task.SetTags(ParseTags(" ui, backend,,ui ")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Join(task.Tags, "|") != "ui|backend" {
+		t.Fatalf("expected tags [ui backend], got %v", task.Tags)
+	}
+	if !task.HasTag("backend") || task.HasTag("db") {
+		t.Fatalf("expected only tags ui and backend, got %v", task.Tags)
+	}
+	if err = task.SetTags([]string{"a,b"}); !errors.Is(err, ErrTag) {
+		t.Fatalf("expected error %v, got %v", ErrTag, err)
+	}
+}
+
+func TestSetPriorityAndDue(t *testing.T) {
+	task, err := New("Title", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err = task.SetPriority(PriorityHigh); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err = task.SetPriority("urgent"); !errors.Is(err, ErrPriority) || task.Priority != PriorityHigh {
+		t.Fatalf("expected error %v and priority %s, got %v and %s", ErrPriority, PriorityHigh, err, task.Priority)
+	}
+
+	due, err := ParseDue("2024-11-01")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	task.SetDue(due.Add(15 * time.Hour))
+	if FormatDue(task.Due) != "2024-11-01" || !task.Due.Equal(due) {
+		t.Fatalf("expected due date 2024-11-01, got %s", task.Due)
+	}
+	task.SetDue(time.Time{})
+	if !task.Due.IsZero() || FormatDue(task.Due) != "" {
+		t.Fatalf("expected no due date, got %s", task.Due)
+	}
+
+	if _, err = ParseDue("tomorrow"); !errors.Is(err, ErrDue) {
+		t.Fatalf("expected error %v, got %v", ErrDue, err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := func() *Task {
+		ts := time.Date(2024, 10, 1, 12, 0, 0, 0, time.UTC)
+		return &Task{ID: uuid.New(), Title: "Title", Status: StatusTodo, Created: ts, Modified: ts}
+	}
+
+	tests := []struct {
+		name          string
+		mutate        func(*Task)
+		expectedError error
+	}{
+		{name: "Valid task", mutate: func(*Task) {}, expectedError: nil},
+		{name: "Missing id", mutate: func(t *Task) { t.ID = uuid.Nil }, expectedError: ErrMissingID},
+		{name: "Empty title", mutate: func(t *Task) { t.Title = "" }, expectedError: ErrEmptyTitle},
+		{name: "Unknown status", mutate: func(t *Task) { t.Status = "blocked" }, expectedError: ErrStatus},
+		{name: "Modified before created", mutate: func(t *Task) { t.Modified = t.Created.Add(-time.Second) }, expectedError: ErrTimestamps},
+		{name: "Tag with comma", mutate: func(t *Task) { t.Tags = []string{"a,b"} }, expectedError: ErrTag},
+		{name: "Duplicate tag", mutate: func(t *Task) { t.Tags = []string{"a", "a"} }, expectedError: ErrTag},
+		{name: "Unknown priority", mutate: func(t *Task) { t.Priority = "urgent" }, expectedError: ErrPriority},
+		{name: "Due with time of day", mutate: func(t *Task) { t.Due = t.Created }, expectedError: ErrDue},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			task := valid()
+			test.mutate(task)
+			if err := task.Validate(); !errors.Is(err, test.expectedError) {
+				t.Fatalf("expected error %v, got %v", test.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestDiff(t *testing.T) {
+	ts := time.Date(2024, 10, 1, 12, 0, 0, 0, time.UTC)
+	old := &Task{ID: uuid.New(), Title: "Title", Status: StatusTodo, Created: ts, Modified: ts, Description: "Old"}
+
+	t.Run("Unchanged", func(t *testing.T) {
+		clone := *old
+		if changes := Diff(old, &clone); len(changes) != 0 {
+			t.Fatalf("Expected no changes, got %+v", changes)
+		}
+	})
+
+	t.Run("Changed fields", func(t *testing.T) {
+		changed := *old
+		changed.Description = "New"
+		changed.Modified = ts.Add(time.Hour)
+
+		changes := Diff(old, &changed)
+		expected := []FieldChange{
+			{Field: "Modified", Old: "2024-10-01T12:00:00Z", New: "2024-10-01T13:00:00Z"},
+			{Field: "Description", Old: "Old", New: "New"},
+		}
+		if len(changes) != len(expected) {
+			t.Fatalf("Expected changes %+v, got %+v", expected, changes)
+		}
+		for i := range expected {
+			if changes[i] != expected[i] {
+				t.Fatalf("Expected change %+v, got %+v", expected[i], changes[i])
+			}
+		}
+	})
+
+	t.Run("Created task", func(t *testing.T) {
+		changes := Diff(nil, old)
+		if len(changes) != 6 || changes[0].Old != "" || changes[0].New != old.ID.String() {
+			t.Fatalf("Expected every field to be added, got %+v", changes)
+		}
+	})
+}